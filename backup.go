@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"runtime"
@@ -13,29 +12,41 @@ import (
 
 	"cloud.google.com/go/pubsub"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/pubsettings"
 	"github.com/sirupsen/logrus"
 )
 
 // Client Global PubSub
 var client *pubsub.Client
+var clientErr error
 var once sync.Once
 
-// CreateClient
+// CreateClient; o erro fica em clientErr e é devolvido a cada requisição
 func createClient() {
 	var projectID = os.Getenv("GCP_PROJECT_ID")
-	var err error
 
-	client, err = pubsub.NewClient(context.Background(), projectID)
-	if err != nil {
-		logrus.Fatalf("pubsub.NewClient: %v", err)
+	client, clientErr = pubsub.NewClient(context.Background(), projectID)
+	if clientErr != nil {
+		logrus.Errorf("pubsub.NewClient: %v", clientErr)
 	}
 }
 
+// Batching e flow control (PUBLISH_* e FLOW_CONTROL_*), lidos e validados
+// uma vez por instância como no pacote publisher
+var publishCfg pubsettings.PublishConfig
+var publishCfgErr error
+
 func init() {
 	//
 	runtime.GOMAXPROCS(1)
 	// Registrando HTTP Function
 	functions.HTTP("Main", PublishMessage)
+
+	publishCfg, publishCfgErr = pubsettings.Load()
+	if publishCfgErr != nil && os.Getenv("FUNCTION_TARGET") != "" {
+		// Servindo a function, uma configuração inválida impede a instância de subir
+		logrus.Fatalf("Invalid configuration: %v", publishCfgErr)
+	}
 }
 
 type Message struct {
@@ -43,41 +54,50 @@ type Message struct {
 	Message string `json:"message"`
 }
 
-func fetchMessages() ([]Message, error) {
+// fetchMessages decodifica a resposta em streaming e entrega cada mensagem a fn
+func fetchMessages(fn func(Message)) (int, error) {
 	// URL do endpoint
 	url := os.Getenv("ENDPOINT_SERVER")
 	if url == "" {
-		return nil, fmt.Errorf("ENDPOINT_SERVER is not set")
+		return 0, fmt.Errorf("ENDPOINT_SERVER is not set")
 	}
 	logrus.Debugf("Fetching URL: %s", url)
 
 	// Fazendo a requisição GET
 	resp, err := http.Get(url)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao fazer a requisição: %w", err)
+		return 0, fmt.Errorf("erro ao fazer a requisição: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Lendo o corpo da resposta
-	logrus.Debug("Request successful, reading body")
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("erro ao ler o corpo da resposta: %w", err)
+	// Lendo o corpo da resposta um elemento por vez
+	logrus.Debug("Request successful, decoding body")
+	dec := json.NewDecoder(resp.Body)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return 0, fmt.Errorf("erro ao fazer parse do JSON: esperado array (%v, %v)", tok, err)
 	}
 
-	// Parse do JSON recebido
-	var messages []Message
-	if err := json.Unmarshal(body, &messages); err != nil {
-		return nil, fmt.Errorf("erro ao fazer parse do JSON: %w", err)
+	count := 0
+	for dec.More() {
+		var msg Message
+		if err := dec.Decode(&msg); err != nil {
+			return count, fmt.Errorf("erro ao fazer parse do JSON: %w", err)
+		}
+		count++
+		fn(msg)
 	}
 
-	return messages, nil
+	return count, nil
 }
 
 func PublishMessage(w http.ResponseWriter, r *http.Request) {
 	logrus.SetLevel(logrus.DebugLevel)
 
-	var messages []Message
+	if publishCfgErr != nil {
+		http.Error(w, fmt.Sprintf("configuração inválida: %v", publishCfgErr), http.StatusInternalServerError)
+		return
+	}
+
 	var topicID string = os.Getenv("TOPIC_ID")
 	if topicID == "" {
 		http.Error(w, "TOPIC_ID is not set", http.StatusInternalServerError)
@@ -85,47 +105,69 @@ func PublishMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	once.Do(createClient)
-
-	messages, err := fetchMessages()
-	if err != nil {
-		logrus.Fatalf("Falha ao recuperar mensagens: %v", err)
-		http.Error(w, fmt.Sprintf("Falha ao recuperar mensagens: %v", err), http.StatusInternalServerError)
+	if clientErr != nil {
+		http.Error(w, fmt.Sprintf("pubsub.NewClient: %v", clientErr), http.StatusInternalServerError)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(10)*time.Minute)
 	defer cancel()
 
-	var wg sync.WaitGroup
+	// Um tópico por requisição; o controle de fluxo (por padrão, bloquear o
+	// Publish) evita acumular tudo em memória quando há mensagens demais pendentes
+	topic := client.Topic(topicID)
+	publishCfg.Apply(topic)
+	defer topic.Stop()
+
+	// Resultados dos Publish, coletados à parte; só esta goroutine escreve em w
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		published int
+		failures  []error
+	)
+
+	_, err := fetchMessages(func(msg Message) {
+		messageJSON, err := json.Marshal(msg)
+		if err != nil {
+			logrus.Errorf("Erro ao converter mensagem para JSON: %v", err)
+			mu.Lock()
+			failures = append(failures, err)
+			mu.Unlock()
+			return
+		}
+
+		startTime := time.Now()
+		result := topic.Publish(ctx, &pubsub.Message{Data: messageJSON})
 
-	for _, msg := range messages {
 		wg.Add(1)
-		go func(msg Message) {
+		go func() {
 			defer wg.Done()
-
-			messageJSON, err := json.Marshal(msg)
-			if err != nil {
-				logrus.Errorf("Erro ao converter mensagem para JSON: %v", err)
-				return
-			}
-
-			m := &pubsub.Message{
-				Data: []byte(messageJSON),
-			}
-
-			startTime := time.Now()
-			id, err := client.Topic(topicID).Publish(ctx, m).Get(ctx)
+			id, err := result.Get(ctx)
 			duration := time.Since(startTime)
 
+			mu.Lock()
+			defer mu.Unlock()
 			if err != nil {
 				logrus.Errorf("topic(%s).Publish.Get (durou %v): %v", topicID, duration, err)
-				http.Error(w, fmt.Sprintf("Erro ao publicar a mensagem: %v", err), http.StatusInternalServerError)
+				failures = append(failures, err)
 				return
 			}
 			logrus.Infof("Mensagem publicada (durou %v): %v", duration, id)
-			fmt.Fprintf(w, "Mensagem publicada: %v\n", id)
-		}(msg)
-	}
+			published++
+		}()
+	})
 	wg.Wait()
+
+	if err != nil {
+		logrus.Errorf("Falha ao recuperar mensagens: %v", err)
+		http.Error(w, fmt.Sprintf("Falha ao recuperar mensagens (%d publicadas): %v", published, err), http.StatusInternalServerError)
+		return
+	}
+	if len(failures) > 0 {
+		http.Error(w, fmt.Sprintf("Erro ao publicar %d mensagens (%d publicadas): %v", len(failures), published, failures[0]), http.StatusInternalServerError)
+		return
+	}
 	logrus.Debug("ALL Messages Published sucessfully")
+	fmt.Fprintf(w, "Mensagens publicadas: %d\n", published)
 }
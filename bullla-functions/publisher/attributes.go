@@ -0,0 +1,150 @@
+package publisher
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/message"
+)
+
+// Limites do Pub/Sub para cada mensagem
+const (
+	maxAttributes     = 100
+	maxAttributeKey   = 256
+	maxAttributeValue = 1024
+	maxMessageSize    = 10 << 20 // dados + atributos + ordering key
+)
+
+// Atributos padrão de todas as mensagens
+const (
+	attrSourceURL     = "source_url"
+	attrSource        = "source" // tag da origem em um fan-in
+	attrRunID         = "run_id"
+	attrSchemaVersion = "schema_version"
+	attrContentType   = "content_type"
+	attrFetchedAt     = "fetched_at"
+)
+
+var standardAttributes = []string{attrSourceURL, attrSource, attrRunID, attrSchemaVersion, attrContentType, attrFetchedAt,
+	message.AttrContentEncoding, message.AttrClaimCheck, message.AttrEncryptionKey, message.AttrEncryptedDEK, message.AttrEncryptedFields}
+
+// AttributeSpec copia um campo do payload para um atributo da mensagem
+type AttributeSpec struct {
+	Name  string
+	Field string
+}
+
+// parseAttributes lê o ATTRIBUTES, no formato "nome=campo,nome=campo". Sem
+// "=", o atributo tem o nome do campo.
+func parseAttributes(raw string) ([]AttributeSpec, error) {
+	var specs []AttributeSpec
+	seen := map[string]bool{}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, field, ok := strings.Cut(item, "=")
+		if !ok {
+			field = name
+		}
+		name, field = strings.TrimSpace(name), strings.TrimSpace(field)
+		if field == "" {
+			return nil, fmt.Errorf("ATTRIBUTES inválido: campo vazio em %q", item)
+		}
+		if err := validateAttributeName(name); err != nil {
+			return nil, fmt.Errorf("ATTRIBUTES inválido: %w", err)
+		}
+		for _, std := range standardAttributes {
+			if name == std {
+				return nil, fmt.Errorf("ATTRIBUTES inválido: %q é um atributo padrão", name)
+			}
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("ATTRIBUTES inválido: atributo %q repetido", name)
+		}
+		seen[name] = true
+		specs = append(specs, AttributeSpec{Name: name, Field: field})
+	}
+	if len(specs)+len(standardAttributes) > maxAttributes {
+		return nil, fmt.Errorf("ATTRIBUTES inválido: no máximo %d atributos além dos padrão", maxAttributes-len(standardAttributes))
+	}
+	return specs, nil
+}
+
+func validateAttributeName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("nome de atributo vazio")
+	case len(name) > maxAttributeKey:
+		return fmt.Errorf("nome de atributo com mais de %d bytes", maxAttributeKey)
+	case strings.HasPrefix(name, "goog"):
+		return fmt.Errorf("nome de atributo %q reservado (goog*)", name)
+	}
+	return nil
+}
+
+// attributes monta os atributos da mensagem: os padrão e os extraídos do
+// payload. Valores vazios não são enviados.
+func (r *run) attributes(msg Record, tag string, fetchedAt time.Time) map[string]string {
+	p := r.p
+	attrs := map[string]string{
+		attrSourceURL:     p.sourceURLs[tag],
+		attrSource:        tag,
+		attrRunID:         r.result.RunID,
+		attrSchemaVersion: p.SchemaVersion,
+		attrContentType:   "application/json",
+		attrFetchedAt:     fetchedAt.UTC().Format(time.RFC3339Nano),
+	}
+	for _, spec := range p.Attributes {
+		if v, ok := recordField(msg, spec.Field); ok {
+			attrs[spec.Name] = v
+		}
+	}
+	for k, v := range attrs {
+		if v == "" {
+			delete(attrs, k)
+		}
+	}
+	return attrs
+}
+
+// validateMessage confere os limites do Pub/Sub antes de publicar, para que
+// a mensagem seja rejeitada com um motivo claro em vez de falhar no envio
+func validateMessage(data []byte, attrs map[string]string, orderingKey string) []string {
+	var reasons []string
+	if len(attrs) > maxAttributes {
+		reasons = append(reasons, fmt.Sprintf("%d atributos (máximo %d)", len(attrs), maxAttributes))
+	}
+	size := len(data) + len(orderingKey)
+	for k, v := range attrs {
+		size += len(k) + len(v)
+		if len(v) > maxAttributeValue {
+			reasons = append(reasons, fmt.Sprintf("atributo %s com %d bytes (máximo %d)", k, len(v), maxAttributeValue))
+		}
+	}
+	if size > maxMessageSize {
+		reasons = append(reasons, fmt.Sprintf("mensagem com %d bytes (máximo %d)", size, maxMessageSize))
+	}
+	return reasons
+}
+
+// sourceURL descreve a origem para o atributo source_url, sem query string
+// nem credenciais
+func sourceURL(cfg *Config) string {
+	switch cfg.SourceType {
+	case sourceHTTP:
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil {
+			return ""
+		}
+		u.User, u.RawQuery, u.Fragment = nil, "", ""
+		return u.String()
+	case sourceStdin:
+		return "stdin"
+	default:
+		return cfg.SourcePath
+	}
+}
@@ -0,0 +1,96 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAttributes(t *testing.T) {
+	specs, err := parseAttributes("tipo, cliente=customer.id ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[{tipo tipo} {cliente customer.id}]"; fmt.Sprint(specs) != want {
+		t.Errorf("parseAttributes = %v, want %s", specs, want)
+	}
+
+	for _, raw := range []string{"x=", "run_id=id", "googx=id", "a=id,a=tipo", strings.Repeat("a", 257) + "=id"} {
+		if _, err := parseAttributes(raw); err == nil {
+			t.Errorf("parseAttributes(%.20q) aceitou os atributos", raw)
+		}
+	}
+}
+
+func TestValidateMessage(t *testing.T) {
+	if reasons := validateMessage([]byte("{}"), map[string]string{"a": "b"}, "key"); len(reasons) > 0 {
+		t.Errorf("mensagem válida rejeitada: %v", reasons)
+	}
+	attrs := map[string]string{"grande": strings.Repeat("x", maxAttributeValue+1)}
+	for i := 0; i < maxAttributes; i++ {
+		attrs[fmt.Sprint("a", i)] = "v"
+	}
+	reasons := validateMessage(make([]byte, maxMessageSize), attrs, "")
+	if len(reasons) != 3 {
+		t.Errorf("motivos = %v, want atributos demais, valor grande e tamanho", reasons)
+	}
+}
+
+func TestRunAttributes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 1, "tipo": "pedido", "customer": {"id": 7}}, {"id": 2, "tipo": ""}]`)
+	}))
+	defer srv.Close()
+
+	u := strings.Replace(srv.URL, "http://", "http://usuario:senha@", 1)
+	cfg := loadTestConfig(t, map[string]string{
+		"ENDPOINT_SERVER": u + "/items?token=segredo",
+		"TOPIC_ID":        "topico",
+		"SCHEMA_VERSION":  "v2",
+		"ATTRIBUTES":      "tipo,cliente=customer.id",
+		"DRY_RUN":         "true",
+	})
+	p, err := NewPipeline(cfg, newTestClient(t, "topico"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	result, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Messages) != 2 {
+		t.Fatalf("%d mensagens, want 2", len(result.Messages))
+	}
+
+	attrs := result.Messages[0].Attributes
+	want := map[string]string{
+		attrSourceURL:     srv.URL + "/items",
+		attrRunID:         result.RunID,
+		attrSchemaVersion: "v2",
+		attrContentType:   "application/json",
+		"tipo":            "pedido",
+		"cliente":         "7",
+	}
+	for k, v := range want {
+		if attrs[k] != v {
+			t.Errorf("atributo %s = %q, want %q", k, attrs[k], v)
+		}
+	}
+	if at, err := time.Parse(time.RFC3339Nano, attrs[attrFetchedAt]); err != nil || at.Before(start.Add(-time.Second)) {
+		t.Errorf("fetched_at = %q (%v)", attrs[attrFetchedAt], err)
+	}
+	// Sem fan-in não há source, e valores vazios ou campos ausentes não vão
+	if len(attrs) != len(want)+1 {
+		t.Errorf("atributos = %v", attrs)
+	}
+	for _, k := range []string{attrSource, "tipo", "cliente"} {
+		if v, ok := result.Messages[1].Attributes[k]; ok {
+			t.Errorf("registro 2: atributo %s = %q, want ausente", k, v)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package publisher
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"google.golang.org/api/idtoken"
+)
+
+// Modos de autenticação na origem (SOURCE_AUTH)
+const (
+	authNone    = ""
+	authBearer  = "bearer"
+	authBasic   = "basic"
+	authOAuth2  = "oauth2"
+	authIDToken = "idtoken"
+)
+
+// AuthConfig descreve como autenticar as chamadas à origem HTTP. O mTLS
+// (ClientCert/ClientKey) pode ser combinado com qualquer um dos modos.
+type AuthConfig struct {
+	Mode string
+
+	// bearer
+	Token string
+
+	// basic
+	Username string
+	Password string
+
+	// oauth2 (client credentials)
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// idtoken (Cloud Run / Cloud Functions); padrão: origem do ENDPOINT_SERVER
+	Audience string
+
+	// mTLS
+	ClientCert string
+	ClientKey  string
+	CACert     string
+}
+
+// Os clients ficam em cache entre invocações para reaproveitar conexões e,
+// principalmente, os tokens (renovados só quando expiram)
+var (
+	httpClientsMu sync.Mutex
+	httpClients   = map[string]*http.Client{}
+)
+
+// sourceHTTPClient devolve o client HTTP da origem com a autenticação configurada
+func sourceHTTPClient(cfg *Config) (*http.Client, error) {
+	key := fmt.Sprintf("%s|%+v", cfg.Endpoint, cfg.Auth)
+
+	httpClientsMu.Lock()
+	defer httpClientsMu.Unlock()
+	if c, ok := httpClients[key]; ok {
+		return c, nil
+	}
+
+	c, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	httpClients[key] = c
+	return c, nil
+}
+
+func newHTTPClient(cfg *Config) (*http.Client, error) {
+	auth := cfg.Auth
+	base := http.DefaultTransport.(*http.Transport).Clone()
+
+	if auth.ClientCert != "" || auth.ClientKey != "" || auth.CACert != "" {
+		tlsConfig, err := newTLSConfig(auth)
+		if err != nil {
+			return nil, err
+		}
+		base.TLSClientConfig = tlsConfig
+	}
+
+	// Token sources vivem além da invocação, então não usam o ctx da requisição
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: base})
+
+	var ts oauth2.TokenSource
+	switch auth.Mode {
+	case authNone:
+		return &http.Client{Transport: base}, nil
+	case authBearer:
+		if auth.Token == "" {
+			return nil, fmt.Errorf("SOURCE_TOKEN is not set")
+		}
+		ts = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: auth.Token, TokenType: "Bearer"})
+	case authBasic:
+		if auth.Username == "" {
+			return nil, fmt.Errorf("SOURCE_USERNAME is not set")
+		}
+		return &http.Client{Transport: &basicAuthTransport{username: auth.Username, password: auth.Password, base: base}}, nil
+	case authOAuth2:
+		if auth.TokenURL == "" || auth.ClientID == "" {
+			return nil, fmt.Errorf("OAUTH2_TOKEN_URL e OAUTH2_CLIENT_ID são obrigatórios")
+		}
+		cc := &clientcredentials.Config{
+			ClientID:     auth.ClientID,
+			ClientSecret: auth.ClientSecret,
+			TokenURL:     auth.TokenURL,
+			Scopes:       auth.Scopes,
+		}
+		ts = cc.TokenSource(ctx)
+	case authIDToken:
+		audience := auth.Audience
+		if audience == "" {
+			u, err := url.Parse(cfg.Endpoint)
+			if err != nil || u.Host == "" {
+				return nil, fmt.Errorf("IDTOKEN_AUDIENCE is not set")
+			}
+			audience = u.Scheme + "://" + u.Host
+		}
+		var err error
+		if ts, err = idtoken.NewTokenSource(ctx, audience); err != nil {
+			return nil, fmt.Errorf("idtoken.NewTokenSource: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("SOURCE_AUTH inválido: %q", auth.Mode)
+	}
+
+	logrus.Debugf("Source HTTP client using %s auth", auth.Mode)
+	return &http.Client{
+		Transport: &oauth2.Transport{Source: oauth2.ReuseTokenSource(nil, ts), Base: base},
+	}, nil
+}
+
+func newTLSConfig(auth AuthConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if auth.ClientCert != "" || auth.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(auth.ClientCert, auth.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao carregar o certificado do client: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if auth.CACert != "" {
+		pem, err := os.ReadFile(auth.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler SOURCE_CA_CERT: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("SOURCE_CA_CERT não contém certificados PEM")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// basicAuthTransport adiciona o header Authorization: Basic em cada requisição
+type basicAuthTransport struct {
+	username string
+	password string
+	base     http.RoundTripper
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.base.RoundTrip(req)
+}
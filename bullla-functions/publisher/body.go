@@ -0,0 +1,128 @@
+package publisher
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding são as compressões anunciadas à origem. Com o header
+// definido aqui o transport do Go não descomprime sozinho, então todas
+// passam por responseBody.
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// ErrBodyTooLarge indica que a resposta passou de MAX_BODY_BYTES depois de descomprimida
+var ErrBodyTooLarge = errors.New("corpo da resposta maior que MAX_BODY_BYTES")
+
+// responseBody descomprime o corpo da resposta conforme o Content-Encoding,
+// limita o tamanho descomprimido e conta os bytes lidos antes e depois
+type responseBody struct {
+	io.Reader
+	wire    *countingReader
+	decoded *countingReader
+	close   func() error
+}
+
+func newResponseBody(resp *http.Response, maxBytes int64) (*responseBody, error) {
+	b := &responseBody{wire: &countingReader{r: resp.Body}, close: func() error { return nil }}
+
+	var r io.Reader = b.wire
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao descomprimir gzip: %w", err)
+		}
+		r, b.close = zr, zr.Close
+	case "deflate":
+		// O padrão é zlib, mas alguns servidores mandam deflate puro
+		br := bufio.NewReader(r)
+		if header, err := br.Peek(2); err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao descomprimir deflate: %w", err)
+			}
+			r, b.close = zr, zr.Close
+		} else {
+			fr := flate.NewReader(br)
+			r, b.close = fr, fr.Close
+		}
+	case "br":
+		r = brotli.NewReader(r)
+	case "zstd":
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("erro ao descomprimir zstd: %w", err)
+		}
+		r, b.close = zr, func() error { zr.Close(); return nil }
+	default:
+		return nil, fmt.Errorf("Content-Encoding não suportado: %q", encoding)
+	}
+
+	if maxBytes > 0 {
+		r = &maxBytesReader{r: r, remaining: maxBytes}
+	}
+	b.decoded = &countingReader{r: r}
+	b.Reader = b.decoded
+	return b, nil
+}
+
+func encodingName(resp *http.Response) string {
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		return encoding
+	}
+	return "identity"
+}
+
+// Close libera o descompressor; o corpo da resposta é fechado por quem a recebeu
+func (b *responseBody) Close() error {
+	return b.close()
+}
+
+// countingReader conta os bytes lidos
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// maxBytesReader falha com ErrBodyTooLarge, em vez de truncar, quando o
+// stream passa do limite; quem lê acrescenta o limite à mensagem
+type maxBytesReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.remaining <= 0 {
+		// Só é erro se ainda houver dados depois do limite
+		var one [1]byte
+		n, err := m.r.Read(one[:])
+		if n > 0 {
+			return 0, ErrBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > m.remaining {
+		p = p[:m.remaining]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,130 @@
+package publisher
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compress comprime data com o Content-Encoding informado
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "deflate-raw":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, _ = zstd.NewWriter(&buf)
+	default:
+		return data
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestResponseBody(t *testing.T) {
+	data := []byte(strings.Repeat(`{"id": 1, "name": "registro"}`+"\n", 100))
+	for _, encoding := range []string{"", "identity", "gzip", "deflate", "deflate-raw", "br", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			wire := compress(t, encoding, data)
+			resp := &http.Response{Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(wire))}
+			// O deflate puro chega com o mesmo header do zlib
+			resp.Header.Set("Content-Encoding", strings.TrimSuffix(encoding, "-raw"))
+
+			body, err := newResponseBody(resp, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer body.Close()
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("corpo descomprimido com %d bytes, want %d", len(got), len(data))
+			}
+			if body.decoded.n != int64(len(data)) || body.wire.n != int64(len(wire)) {
+				t.Errorf("bytes = %d (%d no fio), want %d (%d no fio)", body.decoded.n, body.wire.n, len(data), len(wire))
+			}
+		})
+	}
+}
+
+func TestResponseBodyUnsupported(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Content-Encoding": {"compress"}}, Body: http.NoBody}
+	if _, err := newResponseBody(resp, 0); err == nil {
+		t.Error("newResponseBody aceitou Content-Encoding compress")
+	}
+}
+
+func TestResponseBodyMaxBytes(t *testing.T) {
+	data := []byte(strings.Repeat("x", 100))
+	tests := []struct {
+		max     int64
+		tooBig  bool
+		content string
+	}{
+		{0, false, "sem limite"},
+		{100, false, "no limite"},
+		{99, true, "acima do limite"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			// O limite vale para o corpo descomprimido
+			resp := &http.Response{Header: http.Header{"Content-Encoding": {"gzip"}}, Body: io.NopCloser(bytes.NewReader(compress(t, "gzip", data)))}
+			body, err := newResponseBody(resp, tt.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer body.Close()
+			_, err = io.ReadAll(body)
+			if tooBig := errors.Is(err, ErrBodyTooLarge); tooBig != tt.tooBig || (!tooBig && err != nil) {
+				t.Errorf("ReadAll = %v, want ErrBodyTooLarge %v", err, tt.tooBig)
+			}
+		})
+	}
+}
+
+func TestFetchBodyTooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compress(t, "gzip", []byte(`[{"id": 1}, {"id": 2}, {"id": 3}]`)))
+	}))
+	defer srv.Close()
+
+	cfg := loadTestConfig(t, map[string]string{"ENDPOINT_SERVER": srv.URL, "MAX_BODY_BYTES": "20"})
+	src, err := NewSource(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = src.Fetch(context.Background(), func(Record) error { return nil })
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Fetch = %v, want ErrBodyTooLarge", err)
+	}
+	if n := strings.Count(err.Error(), "(20 bytes)"); n != 1 {
+		t.Errorf("erro %q traz o limite %d vezes, want 1", err, n)
+	}
+}
@@ -0,0 +1,185 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Checkpoint guarda até onde a origem já foi publicada
+type Checkpoint struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	HighWater    string    `json:"high_water,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// StateStore persiste os checkpoints por chave. Load devolve nil, nil quando
+// ainda não existe checkpoint para a chave.
+type StateStore interface {
+	Load(ctx context.Context, key string) (*Checkpoint, error)
+	Save(ctx context.Context, key string, cp *Checkpoint) error
+}
+
+// resumable é implementado pelas origens que aceitam leitura incremental
+type resumable interface {
+	Resume(cp *Checkpoint)
+	Validators() (etag, lastModified string)
+}
+
+// NewStateStore cria o StateStore configurado em STATE_STORE, ou nil quando
+// a leitura incremental está desligada
+func NewStateStore(cfg *Config) (StateStore, error) {
+	switch cfg.StateStore {
+	case "":
+		return nil, nil
+	case "file":
+		return &fileStateStore{dir: cfg.StatePath}, nil
+	default:
+		return nil, fmt.Errorf("STATE_STORE inválido: %q", cfg.StateStore)
+	}
+}
+
+// fileStateStore salva cada checkpoint como um arquivo JSON em dir
+type fileStateStore struct {
+	dir string
+}
+
+func (s *fileStateStore) path(key string) string {
+	return filepath.Join(s.dir, safeKey(key)+".json")
+}
+
+func (s *fileStateStore) Load(ctx context.Context, key string) (*Checkpoint, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("checkpoint %s corrompido: %w", key, err)
+	}
+	return &cp, nil
+}
+
+func (s *fileStateStore) Save(ctx context.Context, key string, cp *Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	// Escreve em um arquivo temporário e renomeia, para nunca deixar um
+	// checkpoint pela metade
+	tmp, err := os.CreateTemp(s.dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key))
+}
+
+func safeKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+// ErrDocumentNotFound é devolvido por DocumentStore.Get quando o documento não existe
+var ErrDocumentNotFound = errors.New("documento não encontrado")
+
+// DocumentStore é o formato de um banco de documentos como o Firestore:
+// documentos identificados por coleção e ID. Um adaptador sobre o client do
+// Firestore (Collection(c).Doc(id).Get/Set) basta para compartilhar os
+// checkpoints entre instâncias.
+type DocumentStore interface {
+	Get(ctx context.Context, collection, id string) (map[string]interface{}, error)
+	Set(ctx context.Context, collection, id string, data map[string]interface{}) error
+}
+
+// NewDocumentStateStore salva os checkpoints como documentos da coleção informada
+func NewDocumentStateStore(docs DocumentStore, collection string) StateStore {
+	return &documentStateStore{docs: docs, collection: collection}
+}
+
+type documentStateStore struct {
+	docs       DocumentStore
+	collection string
+}
+
+func (s *documentStateStore) Load(ctx context.Context, key string) (*Checkpoint, error) {
+	doc, err := s.docs.Get(ctx, s.collection, key)
+	if errors.Is(err, ErrDocumentNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("checkpoint %s corrompido: %w", key, err)
+	}
+	return &cp, nil
+}
+
+func (s *documentStateStore) Save(ctx context.Context, key string, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return s.docs.Set(ctx, s.collection, key, doc)
+}
+
+// highWater acompanha o maior valor de um campo entre os registros lidos
+type highWater struct {
+	field string
+	value string
+}
+
+// observe considera o valor do campo em um registro publicado ("" se ausente)
+func (h *highWater) observe(v string) {
+	if v != "" && (h.value == "" || later(v, h.value)) {
+		h.value = v
+	}
+}
+
+// later compara numericamente quando possível; senão compara o texto, o que
+// funciona para datas ISO 8601
+func later(a, b string) bool {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return fa > fb
+	}
+	return a > b
+}
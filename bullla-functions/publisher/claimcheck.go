@@ -0,0 +1,42 @@
+package publisher
+
+import (
+	"fmt"
+
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/message"
+)
+
+// Políticas de limpeza dos blobs (CLAIM_CHECK_CLEANUP)
+const (
+	claimCheckCleanupRun  = "run"  // cada execução remove os vencidos
+	claimCheckCleanupNone = "none" // fica com o ciclo de vida do bucket
+)
+
+// NewBlobStore cria o BlobStore configurado em CLAIM_CHECK_STORE, ou nil
+// quando o claim-check está desligado
+func NewBlobStore(cfg *Config) (message.BlobStore, error) {
+	blobs, err := message.OpenBlobStore(cfg.ClaimCheckStore, cfg.ClaimCheckPath)
+	if err != nil {
+		return nil, fmt.Errorf("CLAIM_CHECK_STORE: %w", err)
+	}
+	return blobs, nil
+}
+
+// validateClaimCheck confere a configuração do claim-check
+func (cfg *Config) validateClaimCheck() error {
+	if cfg.ClaimCheckStore == "" {
+		return nil
+	}
+	switch {
+	case cfg.ClaimCheckThreshold < 1 || cfg.ClaimCheckThreshold > maxMessageSize:
+		return fmt.Errorf("CLAIM_CHECK_THRESHOLD: deve estar entre 1 e %d bytes", maxMessageSize)
+	case cfg.ClaimCheckTTL <= 0:
+		return fmt.Errorf("CLAIM_CHECK_TTL: deve ser maior que zero")
+	}
+	switch cfg.ClaimCheckCleanup {
+	case claimCheckCleanupRun, claimCheckCleanupNone:
+		return nil
+	default:
+		return fmt.Errorf("CLAIM_CHECK_CLEANUP: deve ser %s ou %s", claimCheckCleanupRun, claimCheckCleanupNone)
+	}
+}
@@ -0,0 +1,84 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/message"
+)
+
+func TestRunClaimCheck(t *testing.T) {
+	long := strings.Repeat("x", 200)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"id": 1}, {"id": 2, "texto": %q}]`, long)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	client := newTestClient(t, "topico")
+	cfg := loadTestConfig(t, map[string]string{
+		"ENDPOINT_SERVER":       srv.URL,
+		"TOPIC_ID":              "topico",
+		"CLAIM_CHECK_STORE":     "file",
+		"CLAIM_CHECK_PATH":      dir,
+		"CLAIM_CHECK_THRESHOLD": "100",
+	})
+	run := func(topic string) *Result {
+		t.Helper()
+		c := *cfg
+		c.TopicID = topic
+		p, err := NewPipeline(&c, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	blobs, err := message.OpenBlobStore(message.BlobStoreFile, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Só o payload acima do limite vai para o blob
+	result := run("topico")
+	if result.Published != 2 || result.ClaimChecks != 1 {
+		t.Fatalf("published=%d claim_checks=%d, want 2, 1", result.Published, result.ClaimChecks)
+	}
+	var key string
+	for _, m := range result.Messages {
+		if m.ClaimCheck != "" {
+			key = m.ClaimCheck
+		}
+	}
+	data, err := blobs.Get(context.Background(), key)
+	if err != nil || !strings.Contains(string(data), long) {
+		t.Fatalf("blob %q = %q, %v", key, data, err)
+	}
+
+	// Se a publicação falha, o blob é removido
+	result = run("ausente")
+	if result.Errors != 2 {
+		t.Fatalf("errors=%d, want 2", result.Errors)
+	}
+	checked := 0
+	for _, m := range result.Messages {
+		if m.ClaimCheck == "" {
+			continue
+		}
+		checked++
+		if _, err := blobs.Get(context.Background(), m.ClaimCheck); !errors.Is(err, message.ErrBlobNotFound) {
+			t.Errorf("blob %s da mensagem com falha: %v, want removido", m.ClaimCheck, err)
+		}
+	}
+	if checked != 1 {
+		t.Errorf("%d mensagens com claim-check, want 1", checked)
+	}
+}
@@ -0,0 +1,400 @@
+package publisher
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/message"
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/pubsettings"
+)
+
+// Config reúne as configurações da function lidas das variáveis de ambiente
+type Config struct {
+	TopicID  string
+	Endpoint string
+
+	// Origem dos registros: "http" (padrão), "file", "glob" ou "stdin"
+	SourceType string
+	SourcePath string
+
+	// Fan-in (opcional): várias origens lidas em paralelo, no máximo
+	// SourceConcurrency por vez, publicadas no mesmo tópico
+	Sources           []SourceSpec
+	SourceConcurrency int
+
+	// Parâmetros extras na query de todas as requisições ao ENDPOINT_SERVER
+	QueryParams map[string]string
+
+	// Autenticação nas chamadas ao ENDPOINT_SERVER
+	Auth AuthConfig
+
+	// Tamanho máximo de cada resposta da origem, já descomprimida (0 = sem limite)
+	MaxBodyBytes int64
+
+	// Retentativas e circuit breaker das chamadas ao ENDPOINT_SERVER
+	Retry RetryConfig
+
+	// Paginação da origem: "" (sem paginação), "page", "cursor" ou "link"
+	Pagination  string
+	PageParam   string
+	LimitParam  string
+	PageSize    int
+	PageStart   int
+	CursorField string
+	CursorParam string
+	MaxPages    int
+
+	// Formato da origem: "" (deduzido do Content-Type), "json", "ndjson" ou "csv"
+	Format       string
+	RecordsPath  string
+	CSVDelimiter rune
+
+	// Leitura incremental: STATE_STORE liga o checkpoint; SINCE_FIELD é o
+	// campo do registro usado como high-water mark no parâmetro SINCE_PARAM
+	StateStore    string
+	StatePath     string
+	CheckpointKey string
+	SinceField    string
+	SinceParam    string
+
+	// Mapeamento do registro da origem para o payload: JSON inline (MAPPING)
+	// ou arquivo (MAPPING_FILE)
+	Mapping     string
+	MappingFile string
+
+	// Batching e flow control da publicação
+	Publish PublishConfig
+
+	// Compressão (gzip ou zstd) dos payloads a partir de CompressionThreshold bytes
+	Compression          string
+	CompressionThreshold int
+
+	// Claim-check: payloads maiores que ClaimCheckThreshold vão para o
+	// BlobStore e só a referência é publicada. Com ClaimCheckCleanup "run"
+	// cada execução remove os blobs mais antigos que ClaimCheckTTL.
+	ClaimCheckStore     string
+	ClaimCheckPath      string
+	ClaimCheckThreshold int
+	ClaimCheckTTL       time.Duration
+	ClaimCheckCleanup   string
+
+	// Rotas por conteúdo: JSON inline (ROUTES) ou arquivo (ROUTES_FILE). Os
+	// registros que não casam com nenhuma rota vão para o TopicID.
+	Routes     string
+	RoutesFile string
+
+	// Atributos extraídos do payload (ATTRIBUTES) e versão do schema enviada
+	// no atributo schema_version
+	Attributes    []AttributeSpec
+	SchemaVersion string
+
+	// Ordering key das mensagens: caminho de um campo do payload ou template
+	// com campos entre chaves (vazio = sem ordenação)
+	OrderingKey string
+
+	// Deduplicação entre execuções pelo ID_FIELD: store, arquivo e janela
+	DedupStore string
+	DedupPath  string
+	DedupTTL   time.Duration
+
+	// Validação dos payloads: JSON Schema, tópico dos rejeitados e campo de ID
+	SchemaFile    string
+	RejectTopicID string
+	IDField       string
+
+	// Codificação por schema do tópico: definição Avro (.avsc) ou Protobuf
+	// (.proto) e a codificação do tópico, binary ou json
+	MessageSchemaFile string
+	MessageSchemaType string
+	MessageEncoding   string
+
+	// Criptografia dos campos EncryptFields: a chave de dados de cada
+	// execução é cifrada pela EncryptionKey do KMS (ENCRYPTION_KMS; "local"
+	// usa o keyring de EncryptionKeyring)
+	EncryptFields     []string
+	EncryptionKMS     string
+	EncryptionKey     string
+	EncryptionKeyring string
+
+	// Seleção dos registros publicados e execução sem publicar. Filter é uma
+	// expressão CEL (FILTER); a requisição pode substituir ela e a Selection.
+	// Em DryRun (DRY_RUN) a resposta traz o payload dos primeiros
+	// DryRunSamples registros.
+	Selection     Selection
+	Filters       map[string]interface{}
+	Filter        string
+	DryRun        bool
+	DryRunSamples int
+
+	// ReportMessages (REPORT_MESSAGES) é quantos resultados de mensagens
+	// publicadas a resposta traz; as falhas vêm todas, até maxReportMessages
+	ReportMessages int
+}
+
+// LoadConfig lê a configuração do ambiente
+func LoadConfig() (*Config, error) {
+	cfg := &Config{
+		TopicID:           os.Getenv("TOPIC_ID"),
+		Endpoint:          os.Getenv("ENDPOINT_SERVER"),
+		SourceType:        getEnv("SOURCE_TYPE", sourceHTTP),
+		SourcePath:        os.Getenv("SOURCE_PATH"),
+		Pagination:        os.Getenv("PAGINATION"),
+		PageParam:         getEnv("PAGE_PARAM", "page"),
+		LimitParam:        getEnv("LIMIT_PARAM", "limit"),
+		CursorField:       getEnv("CURSOR_FIELD", "next"),
+		CursorParam:       getEnv("CURSOR_PARAM", "cursor"),
+		Format:            os.Getenv("SOURCE_FORMAT"),
+		RecordsPath:       os.Getenv("RECORDS_PATH"),
+		StateStore:        os.Getenv("STATE_STORE"),
+		StatePath:         getEnv("STATE_PATH", "state"),
+		DedupStore:        os.Getenv("DEDUP_STORE"),
+		DedupPath:         getEnv("DEDUP_PATH", "state/dedup.db"),
+		SinceField:        os.Getenv("SINCE_FIELD"),
+		SinceParam:        getEnv("SINCE_PARAM", "since"),
+		Mapping:           os.Getenv("MAPPING"),
+		MappingFile:       os.Getenv("MAPPING_FILE"),
+		Routes:            os.Getenv("ROUTES"),
+		RoutesFile:        os.Getenv("ROUTES_FILE"),
+		SchemaFile:        os.Getenv("SCHEMA_FILE"),
+		MessageSchemaFile: os.Getenv("MESSAGE_SCHEMA_FILE"),
+		MessageSchemaType: strings.ToLower(os.Getenv("MESSAGE_SCHEMA_TYPE")),
+		MessageEncoding:   strings.ToLower(getEnv("MESSAGE_ENCODING", message.EncodingBinary)),
+		EncryptFields:     getEnvList("ENCRYPT_FIELDS"),
+		EncryptionKMS:     os.Getenv("ENCRYPTION_KMS"),
+		EncryptionKey:     os.Getenv("ENCRYPTION_KEY"),
+		EncryptionKeyring: os.Getenv("ENCRYPTION_KEYRING_FILE"),
+		Filter:            os.Getenv("FILTER"),
+		OrderingKey:       os.Getenv("ORDERING_KEY"),
+		SchemaVersion:     os.Getenv("SCHEMA_VERSION"),
+		Compression:       strings.ToLower(os.Getenv("PAYLOAD_COMPRESSION")),
+		ClaimCheckStore:   os.Getenv("CLAIM_CHECK_STORE"),
+		ClaimCheckPath:    getEnv("CLAIM_CHECK_PATH", "state/blobs"),
+		ClaimCheckCleanup: getEnv("CLAIM_CHECK_CLEANUP", claimCheckCleanupRun),
+		Selection:         Selection{Mode: getEnv("SELECT_MODE", selectHead)},
+		IDField:           getEnv("ID_FIELD", "id"),
+	}
+	cfg.RejectTopicID = os.Getenv("REJECT_TOPIC_ID")
+	cfg.CheckpointKey = getEnv("CHECKPOINT_KEY", cfg.TopicID)
+
+	cfg.Auth = AuthConfig{
+		Mode:         os.Getenv("SOURCE_AUTH"),
+		Token:        os.Getenv("SOURCE_TOKEN"),
+		Username:     os.Getenv("SOURCE_USERNAME"),
+		Password:     os.Getenv("SOURCE_PASSWORD"),
+		TokenURL:     os.Getenv("OAUTH2_TOKEN_URL"),
+		ClientID:     os.Getenv("OAUTH2_CLIENT_ID"),
+		ClientSecret: os.Getenv("OAUTH2_CLIENT_SECRET"),
+		Scopes:       getEnvList("OAUTH2_SCOPES"),
+		Audience:     os.Getenv("IDTOKEN_AUDIENCE"),
+		ClientCert:   os.Getenv("SOURCE_CLIENT_CERT"),
+		ClientKey:    os.Getenv("SOURCE_CLIENT_KEY"),
+		CACert:       os.Getenv("SOURCE_CA_CERT"),
+	}
+
+	var err error
+	if cfg.PageSize, err = getEnvInt("PAGE_SIZE", 100); err != nil {
+		return nil, err
+	}
+	if cfg.PageStart, err = getEnvInt("PAGE_START", 1); err != nil {
+		return nil, err
+	}
+	if cfg.MaxPages, err = getEnvInt("MAX_PAGES", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxBodyBytes, err = pubsettings.EnvBytes("MAX_BODY_BYTES", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Publish, err = pubsettings.Load(); err != nil {
+		return nil, err
+	}
+	if err := message.ValidateCompression(cfg.Compression); err != nil {
+		return nil, fmt.Errorf("PAYLOAD_COMPRESSION: %w", err)
+	}
+	if cfg.CompressionThreshold, err = pubsettings.EnvIntBytes("COMPRESSION_THRESHOLD", 1<<10); err != nil {
+		return nil, err
+	}
+	if cfg.ClaimCheckThreshold, err = pubsettings.EnvIntBytes("CLAIM_CHECK_THRESHOLD", 8<<20); err != nil {
+		return nil, err
+	}
+	if cfg.ClaimCheckTTL, err = getEnvDuration("CLAIM_CHECK_TTL", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateClaimCheck(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateMessageSchema(); err != nil {
+		return nil, err
+	}
+	if cfg.Attributes, err = parseAttributes(os.Getenv("ATTRIBUTES")); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateEncryption(); err != nil {
+		return nil, err
+	}
+	if cfg.SourceConcurrency, err = getEnvInt("SOURCE_CONCURRENCY", 4); err != nil {
+		return nil, err
+	}
+	if raw := os.Getenv("SOURCES"); raw != "" {
+		if cfg.Sources, err = parseSources(raw); err != nil {
+			return nil, err
+		}
+		// O checkpoint guarda um único ETag e uma única high-water mark
+		if cfg.StateStore != "" {
+			return nil, fmt.Errorf("STATE_STORE não é suportado com SOURCES")
+		}
+	}
+	if cfg.Selection.Limit, err = getEnvInt("LIMIT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Selection.Offset, err = getEnvInt("OFFSET", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Selection.Sample, err = getEnvFloat("SAMPLE_PERCENT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DryRun, err = getEnvBool("DRY_RUN", false); err != nil {
+		return nil, err
+	}
+	if cfg.DryRunSamples, err = getEnvInt("DRY_RUN_SAMPLES", 10); err != nil {
+		return nil, err
+	}
+	if cfg.DryRunSamples < 0 || cfg.DryRunSamples > maxReportMessages {
+		return nil, fmt.Errorf("DRY_RUN_SAMPLES deve estar entre 0 e %d", maxReportMessages)
+	}
+	if cfg.ReportMessages, err = getEnvInt("REPORT_MESSAGES", 100); err != nil {
+		return nil, err
+	}
+	if cfg.ReportMessages < 0 || cfg.ReportMessages > maxReportMessages {
+		return nil, fmt.Errorf("REPORT_MESSAGES deve estar entre 0 e %d", maxReportMessages)
+	}
+	if cfg.Retry.MaxAttempts, err = getEnvInt("FETCH_MAX_ATTEMPTS", 4); err != nil {
+		return nil, err
+	}
+	if cfg.Retry.AttemptTimeout, err = getEnvDuration("FETCH_ATTEMPT_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Retry.TotalTimeout, err = getEnvDuration("FETCH_TOTAL_TIMEOUT", 2*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.Retry.BackoffInitial, err = getEnvDuration("FETCH_BACKOFF_INITIAL", 500*time.Millisecond); err != nil {
+		return nil, err
+	}
+	if cfg.Retry.BackoffMax, err = getEnvDuration("FETCH_BACKOFF_MAX", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Retry.BreakerThreshold, err = getEnvInt("BREAKER_THRESHOLD", 5); err != nil {
+		return nil, err
+	}
+	if cfg.Retry.BreakerCooldown, err = getEnvDuration("BREAKER_COOLDOWN", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.DedupTTL, err = getEnvDuration("DEDUP_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.Retry.MaxAttempts < 1 {
+		return nil, fmt.Errorf("FETCH_MAX_ATTEMPTS deve ser pelo menos 1")
+	}
+
+	if err := cfg.Selection.Validate(); err != nil {
+		return nil, fmt.Errorf("seleção inválida: %w", err)
+	}
+
+	switch cfg.Format {
+	case "auto":
+		cfg.Format = ""
+	case "", formatJSON, formatNDJSON, formatCSV:
+	default:
+		return nil, fmt.Errorf("SOURCE_FORMAT inválido: %q", cfg.Format)
+	}
+
+	switch cfg.Pagination {
+	case "", "page", "link":
+	case "cursor":
+		// O cursor só existe em envelopes JSON
+		if cfg.Format != "" && cfg.Format != formatJSON {
+			return nil, fmt.Errorf("PAGINATION=cursor exige SOURCE_FORMAT=json")
+		}
+		if cfg.RecordsPath == "" {
+			cfg.RecordsPath = "data"
+		}
+	default:
+		return nil, fmt.Errorf("PAGINATION inválido: %q", cfg.Pagination)
+	}
+
+	delimiter := []rune(getEnv("CSV_DELIMITER", ","))
+	if len(delimiter) != 1 {
+		return nil, fmt.Errorf("CSV_DELIMITER deve ter um único caractere")
+	}
+	cfg.CSVDelimiter = delimiter[0]
+
+	return cfg, nil
+}
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// getEnvList lê uma lista separada por vírgulas
+func getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func getEnvDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s inválido: %w", key, err)
+	}
+	return d, nil
+}
+
+func getEnvInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s inválido: %w", key, err)
+	}
+	return n, nil
+}
+
+func getEnvBool(key string, def bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s inválido: %w", key, err)
+	}
+	return b, nil
+}
+
+func getEnvFloat(key string, def float64) (float64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s inválido: %w", key, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,252 @@
+package publisher
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// Formatos de origem suportados
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+// formatFromContentType deduz o formato do corpo pelo Content-Type da resposta.
+// Na dúvida assume JSON, que era o único formato suportado.
+func formatFromContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return formatJSON
+	}
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl",
+		"application/x-jsonlines", "application/jsonlines":
+		return formatNDJSON
+	case "text/csv", "application/csv":
+		return formatCSV
+	default:
+		return formatJSON
+	}
+}
+
+// decodeBody decodifica o corpo no formato informado e entrega cada registro
+// a fn. Para JSON, recordsPath aponta o array de registros dentro do envelope
+// e cursorPath o campo com o cursor da próxima página (ambos opcionais).
+func decodeBody(r io.Reader, format string, cfg *Config, cursorPath string, fn func(Record) error) (int, string, error) {
+	switch format {
+	case formatNDJSON:
+		count, err := decodeNDJSON(newDecoder(r), fn)
+		return count, "", err
+	case formatCSV:
+		count, err := decodeCSV(r, cfg.CSVDelimiter, fn)
+		return count, "", err
+	default:
+		dec := newDecoder(r)
+		if cfg.RecordsPath == "" && cursorPath == "" {
+			count, err := decodeArray(dec, fn)
+			return count, "", err
+		}
+		return decodeEnvelope(dec, cfg.RecordsPath, cursorPath, fn)
+	}
+}
+
+// newDecoder preserva os números como json.Number, para que IDs grandes não
+// percam precisão ao passar por float64
+func newDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec
+}
+
+// decodeArray lê um array JSON elemento a elemento e entrega cada registro
+// a fn. Só um elemento fica em memória por vez; se fn bloquear (flow control
+// do tópico) a leitura do corpo também para.
+func decodeArray(dec *json.Decoder, fn func(Record) error) (int, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, fmt.Errorf("erro ao fazer parse do JSON: %w", err)
+	}
+	if tok == nil {
+		// null equivale a uma lista vazia
+		return 0, nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return 0, fmt.Errorf("erro ao fazer parse do JSON: esperado '[', encontrado %v", tok)
+	}
+
+	count := 0
+	for dec.More() {
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			return count, fmt.Errorf("erro ao fazer parse do registro %d: %w", count, err)
+		}
+		count++
+		if err := fn(rec); err != nil {
+			return count, err
+		}
+	}
+
+	if err := expectDelim(dec, ']'); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+// decodeEnvelope lê um objeto JSON como {"data": [...], "total": N, "next": ...},
+// fazendo streaming do array em recordsPath (caminho separado por pontos, ex.
+// "result.items") e devolvendo o valor em cursorPath. Os demais campos são
+// ignorados.
+func decodeEnvelope(dec *json.Decoder, recordsPath, cursorPath string, fn func(Record) error) (int, string, error) {
+	if err := expectDelim(dec, '{'); err != nil {
+		return 0, "", err
+	}
+	e := &envelope{recordsPath: recordsPath, cursorPath: cursorPath, fn: fn}
+	if err := e.walk(dec, ""); err != nil {
+		return e.count, "", err
+	}
+	if recordsPath != "" && !e.found {
+		return 0, "", fmt.Errorf("campo %s não encontrado no JSON", recordsPath)
+	}
+	return e.count, e.cursor, nil
+}
+
+type envelope struct {
+	recordsPath string
+	cursorPath  string
+	fn          func(Record) error
+
+	count  int
+	found  bool
+	cursor string
+}
+
+// walk percorre os campos de um objeto cujo '{' já foi consumido
+func (e *envelope) walk(dec *json.Decoder, prefix string) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("erro ao fazer parse do JSON: %w", err)
+		}
+		key, _ := tok.(string)
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		switch {
+		case path == e.recordsPath:
+			e.found = true
+			n, err := decodeArray(dec, e.fn)
+			e.count += n
+			if err != nil {
+				return err
+			}
+		case path == e.cursorPath:
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return fmt.Errorf("erro ao fazer parse do JSON: %w", err)
+			}
+			if e.cursor, err = cursorValue(raw); err != nil {
+				return fmt.Errorf("campo %s inválido: %w", e.cursorPath, err)
+			}
+		case strings.HasPrefix(e.recordsPath, path+".") || strings.HasPrefix(e.cursorPath, path+"."):
+			tok, err := dec.Token()
+			if err != nil {
+				return fmt.Errorf("erro ao fazer parse do JSON: %w", err)
+			}
+			if tok == nil {
+				continue
+			}
+			if d, ok := tok.(json.Delim); !ok || d != '{' {
+				return fmt.Errorf("erro ao fazer parse do JSON: campo %s não é um objeto", path)
+			}
+			if err := e.walk(dec, path); err != nil {
+				return err
+			}
+		default:
+			// Ignorando os demais campos do envelope
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return fmt.Errorf("erro ao fazer parse do JSON: %w", err)
+			}
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// decodeNDJSON lê um registro JSON por linha
+func decodeNDJSON(dec *json.Decoder, fn func(Record) error) (int, error) {
+	count := 0
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("erro ao fazer parse do registro %d: %w", count, err)
+		}
+		count++
+		if err := fn(rec); err != nil {
+			return count, err
+		}
+	}
+}
+
+// decodeCSV lê um CSV com linha de cabeçalho; cada coluna vira um campo
+// textual do registro. Células vazias são omitidas, para que os defaults do
+// mapeamento se apliquem.
+func decodeCSV(r io.Reader, delimiter rune, fn func(Record) error) (int, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("erro ao ler o cabeçalho do CSV: %w", err)
+	}
+	columns := make([]string, len(header))
+	copy(columns, header)
+
+	count := 0
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("erro ao ler o CSV (registro %d): %w", count, err)
+		}
+
+		rec := make(Record, len(columns))
+		for c, column := range columns {
+			if c < len(row) && row[c] != "" {
+				rec[column] = row[c]
+			}
+		}
+		count++
+		if err := fn(rec); err != nil {
+			return count, err
+		}
+	}
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("erro ao fazer parse do JSON: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("erro ao fazer parse do JSON: esperado %q, encontrado %v", want, tok)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package publisher
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeArrayStreams(t *testing.T) {
+	// Cada registro é entregue assim que chega, antes do fim do corpo
+	r, w := io.Pipe()
+	got := make(chan string)
+	done := make(chan error)
+	go func() {
+		_, err := decodeArray(newDecoder(r), func(rec Record) error {
+			id, _ := recordField(rec, "id")
+			got <- id
+			return nil
+		})
+		done <- err
+	}()
+
+	fmt.Fprint(w, `[{"id": 1}, `)
+	select {
+	case id := <-got:
+		if id != "1" {
+			t.Fatalf("primeiro registro = %s, want 1", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("o primeiro registro não foi entregue antes do fim do corpo")
+	}
+	fmt.Fprint(w, `{"id": 12345678901234567890}]`)
+	w.Close()
+	if id := <-got; id != "12345678901234567890" {
+		t.Errorf("segundo registro = %s, want o número sem perda de precisão", id)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeArrayInvalid(t *testing.T) {
+	for _, body := range []string{`{"id": 1}`, `[{"id": 1}, {"id"`, `[1, 2]`} {
+		_, err := decodeArray(newDecoder(strings.NewReader(body)), func(Record) error { return nil })
+		if err == nil {
+			t.Errorf("decodeArray(%s) = nil, want erro", body)
+		}
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		body       string
+		records    string // RECORDS_PATH
+		cursorPath string
+		want       []string
+		cursor     string
+	}{
+		{
+			name:   "JSON array",
+			format: formatJSON,
+			body:   `[{"id": 1}, {"id": "b"}]`,
+			want:   []string{"1", "b"},
+		},
+		{
+			name:   "NDJSON com linhas em branco",
+			format: formatNDJSON,
+			body:   "{\"id\": 1}\n\n{\"id\": 2}\n",
+			want:   []string{"1", "2"},
+		},
+		{
+			name:   "CSV",
+			format: formatCSV,
+			body:   "id,name\n1,a\n2,b\n",
+			want:   []string{"1", "2"},
+		},
+		{
+			name:       "envelope com cursor",
+			format:     formatJSON,
+			body:       `{"meta": {"total": 2}, "data": {"items": [{"id": 1}, {"id": 2}]}, "next": "abc"}`,
+			records:    "data.items",
+			cursorPath: "next",
+			want:       []string{"1", "2"},
+			cursor:     "abc",
+		},
+		{
+			name:       "envelope com cursor antes dos registros e nulo",
+			format:     formatJSON,
+			body:       `{"paging": {"next": null}, "items": [{"id": 3}]}`,
+			records:    "items",
+			cursorPath: "paging.next",
+			want:       []string{"3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{RecordsPath: tt.records, CSVDelimiter: ','}
+			var ids []string
+			count, cursor, err := decodeBody(strings.NewReader(tt.body), tt.format, cfg, tt.cursorPath, func(rec Record) error {
+				id, _ := recordField(rec, "id")
+				ids = append(ids, id)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != len(tt.want) || !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("registros = %v (%d), want %v", ids, count, tt.want)
+			}
+			if cursor != tt.cursor {
+				t.Errorf("cursor = %q, want %q", cursor, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeBodyInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		body   string
+	}{
+		{"JSON que não é array", formatJSON, `{"id": 1}`},
+		{"JSON truncado", formatJSON, `[{"id": 1}, {"id"`},
+		{"NDJSON com linha inválida", formatNDJSON, "{\"id\": 1}\n[1]\n"},
+		{"CSV com colunas a mais", formatCSV, "id\n1,2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{CSVDelimiter: ','}
+			_, _, err := decodeBody(strings.NewReader(tt.body), tt.format, cfg, "", func(Record) error { return nil })
+			if err == nil {
+				t.Error("decodeBody = nil, want erro")
+			}
+		})
+	}
+}
+
+func TestFormatFromContentType(t *testing.T) {
+	tests := map[string]string{
+		"application/json; charset=utf-8": formatJSON,
+		"application/x-ndjson":            formatNDJSON,
+		"text/csv":                        formatCSV,
+		"":                                formatJSON,
+	}
+	for contentType, want := range tests {
+		if got := formatFromContentType(contentType); got != want {
+			t.Errorf("formatFromContentType(%q) = %q, want %q", contentType, got, want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package publisher
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DedupStore lembra os IDs já publicados sob uma chave (o CHECKPOINT_KEY)
+// por um tempo limitado, para que execuções seguintes não os republiquem.
+type DedupStore interface {
+	// Seen diz se id foi publicado sob key dentro da janela do store
+	Seen(ctx context.Context, key, id string) (bool, error)
+	// Mark registra ids como publicados agora
+	Mark(ctx context.Context, key string, ids []string) error
+}
+
+// NewDedupStore cria o DedupStore configurado em DEDUP_STORE, ou nil quando
+// a deduplicação está desligada
+func NewDedupStore(cfg *Config) (DedupStore, error) {
+	switch cfg.DedupStore {
+	case "":
+		return nil, nil
+	case "bolt":
+		if cfg.DedupTTL <= 0 {
+			return nil, fmt.Errorf("DEDUP_TTL deve ser maior que zero")
+		}
+		db, err := openBolt(cfg.DedupPath)
+		if err != nil {
+			return nil, err
+		}
+		return &boltDedupStore{db: db, ttl: cfg.DedupTTL}, nil
+	default:
+		return nil, fmt.Errorf("DEDUP_STORE inválido: %q", cfg.DedupStore)
+	}
+}
+
+// O bbolt trava o arquivo, então cada banco é aberto uma vez por instância
+// e compartilhado entre invocações
+var (
+	boltDBsMu sync.Mutex
+	boltDBs   = map[string]*bolt.DB{}
+)
+
+func openBolt(path string) (*bolt.DB, error) {
+	boltDBsMu.Lock()
+	defer boltDBsMu.Unlock()
+	if db, ok := boltDBs[path]; ok {
+		return db, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir DEDUP_PATH: %w", err)
+	}
+	boltDBs[path] = db
+	return db, nil
+}
+
+// boltDedupStore guarda um bucket por chave, com o horário de publicação de
+// cada ID. Os expirados são removidos a cada Mark.
+type boltDedupStore struct {
+	db  *bolt.DB
+	ttl time.Duration
+}
+
+func (s *boltDedupStore) Seen(ctx context.Context, key, id string) (bool, error) {
+	var seen bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(key))
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(id)); len(v) == 8 {
+			at := time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+			seen = time.Since(at) < s.ttl
+		}
+		return nil
+	})
+	return seen, err
+}
+
+func (s *boltDedupStore) Mark(ctx context.Context, key string, ids []string) error {
+	now := time.Now()
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(now.UnixNano()))
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		// Limpando os IDs fora da janela antes de gravar os novos. Apagar
+		// durante a iteração do cursor pula chaves, então coleta primeiro.
+		var expired [][]byte
+		err = b.ForEach(func(k, at []byte) error {
+			if len(at) != 8 || now.Sub(time.Unix(0, int64(binary.BigEndian.Uint64(at)))) >= s.ttl {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		for _, id := range ids {
+			if err := b.Put([]byte(id), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// NewDocumentDedupStore guarda cada ID como um documento da coleção
+// informada, com o campo expires_at. No Firestore, uma política de TTL sobre
+// expires_at remove os documentos vencidos.
+func NewDocumentDedupStore(docs DocumentStore, collection string, ttl time.Duration) DedupStore {
+	return &documentDedupStore{docs: docs, collection: collection, ttl: ttl}
+}
+
+type documentDedupStore struct {
+	docs       DocumentStore
+	collection string
+	ttl        time.Duration
+}
+
+func (s *documentDedupStore) docID(key, id string) string {
+	return safeKey(key) + "_" + url.QueryEscape(id)
+}
+
+func (s *documentDedupStore) Seen(ctx context.Context, key, id string) (bool, error) {
+	doc, err := s.docs.Get(ctx, s.collection, s.docID(key, id))
+	if errors.Is(err, ErrDocumentNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	expires, _ := doc["expires_at"].(string)
+	at, err := time.Parse(time.RFC3339Nano, expires)
+	if err != nil {
+		return false, nil
+	}
+	return time.Now().Before(at), nil
+}
+
+func (s *documentDedupStore) Mark(ctx context.Context, key string, ids []string) error {
+	expires := time.Now().Add(s.ttl).UTC().Format(time.RFC3339Nano)
+	for _, id := range ids {
+		doc := map[string]interface{}{"key": key, "id": id, "expires_at": expires}
+		if err := s.docs.Set(ctx, s.collection, s.docID(key, id), doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltDedupStore(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{DedupStore: "bolt", DedupPath: filepath.Join(t.TempDir(), "dedup.db"), DedupTTL: 50 * time.Millisecond}
+	store, err := NewDedupStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// O banco é aberto uma vez por instância
+	again, err := NewDedupStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.(*boltDedupStore).db != again.(*boltDedupStore).db {
+		t.Error("o banco foi aberto de novo")
+	}
+
+	seen := func(key, id string) bool {
+		t.Helper()
+		ok, err := store.Seen(ctx, key, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	if seen("job", "1") {
+		t.Fatal("ID visto antes do Mark")
+	}
+	if err := store.Mark(ctx, "job", []string{"1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if !seen("job", "1") || !seen("job", "2") {
+		t.Error("IDs marcados não foram vistos")
+	}
+	// Cada chave tem os próprios IDs
+	if seen("outro", "1") {
+		t.Error("ID visto sob outra chave")
+	}
+
+	// Fora da janela o ID volta a ser publicado e o próximo Mark o remove
+	time.Sleep(60 * time.Millisecond)
+	if seen("job", "1") {
+		t.Error("ID visto depois do DEDUP_TTL")
+	}
+	if err := store.Mark(ctx, "job", []string{"3"}); err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	store.(*boltDedupStore).db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("job")).ForEach(func(k, _ []byte) error {
+			left = append(left, string(k))
+			return nil
+		})
+	})
+	if fmt.Sprint(left) != "[3]" {
+		t.Errorf("IDs no banco = %v, want [3]", left)
+	}
+}
+
+func TestNewDedupStoreInvalid(t *testing.T) {
+	for _, cfg := range []*Config{
+		{DedupStore: "redis"},
+		{DedupStore: "bolt", DedupPath: filepath.Join(t.TempDir(), "dedup.db")},
+	} {
+		if _, err := NewDedupStore(cfg); err == nil {
+			t.Errorf("NewDedupStore(%+v) aceitou a configuração", *cfg)
+		}
+	}
+}
+
+func TestRunDedup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 1}, {"id": 2}, {"id": 1}, {"id": 3}]`)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, "topico")
+	cfg := loadTestConfig(t, map[string]string{
+		"ENDPOINT_SERVER": srv.URL,
+		"TOPIC_ID":        "topico",
+		"DEDUP_STORE":     "bolt",
+		"DEDUP_PATH":      filepath.Join(t.TempDir(), "dedup.db"),
+	})
+	run := func() *Result {
+		t.Helper()
+		p, err := NewPipeline(cfg, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// O ID repetido na mesma leitura também é suprimido
+	if result := run(); result.Published != 3 || result.Duplicate != 1 {
+		t.Fatalf("published=%d duplicate=%d, want 3, 1", result.Published, result.Duplicate)
+	}
+	if result := run(); result.Published != 0 || result.Duplicate != 4 {
+		t.Fatalf("published=%d duplicate=%d na segunda execução, want 0, 4", result.Published, result.Duplicate)
+	}
+}
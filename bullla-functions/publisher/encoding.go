@@ -0,0 +1,34 @@
+package publisher
+
+import (
+	"fmt"
+
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/message"
+)
+
+// validateMessageSchema confere a configuração da codificação por schema
+func (cfg *Config) validateMessageSchema() error {
+	if cfg.MessageSchemaFile == "" {
+		return nil
+	}
+	if cfg.MessageSchemaType == "" {
+		if cfg.MessageSchemaType = message.SchemaTypeFromPath(cfg.MessageSchemaFile); cfg.MessageSchemaType == "" {
+			return fmt.Errorf("MESSAGE_SCHEMA_TYPE: não deduzido da extensão de %s (use %s ou %s)", cfg.MessageSchemaFile, message.SchemaAvro, message.SchemaProtobuf)
+		}
+	}
+	if cfg.MessageSchemaType != message.SchemaAvro && cfg.MessageSchemaType != message.SchemaProtobuf {
+		return fmt.Errorf("MESSAGE_SCHEMA_TYPE: deve ser %s ou %s", message.SchemaAvro, message.SchemaProtobuf)
+	}
+	if err := message.ValidateEncoding(cfg.MessageEncoding); err != nil {
+		return fmt.Errorf("MESSAGE_ENCODING: %w", err)
+	}
+	// O Pub/Sub valida o payload contra o schema do tópico, então ele não
+	// pode ir comprimido nem como referência a um blob
+	switch {
+	case cfg.Compression != "":
+		return fmt.Errorf("PAYLOAD_COMPRESSION não é suportado com MESSAGE_SCHEMA_FILE")
+	case cfg.ClaimCheckStore != "":
+		return fmt.Errorf("CLAIM_CHECK_STORE não é suportado com MESSAGE_SCHEMA_FILE")
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package publisher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/message"
+)
+
+// NewKMS cria o KMS configurado em ENCRYPTION_KMS, ou nil quando a
+// criptografia está desligada
+func NewKMS(cfg *Config) (message.KMS, error) {
+	kms, err := message.OpenKMS(cfg.EncryptionKMS, cfg.EncryptionKeyring)
+	if err != nil {
+		return nil, fmt.Errorf("ENCRYPTION_KMS: %w", err)
+	}
+	return kms, nil
+}
+
+// validateEncryption confere a configuração da criptografia dos campos
+func (cfg *Config) validateEncryption() error {
+	if len(cfg.EncryptFields) == 0 {
+		return nil
+	}
+	switch {
+	case cfg.EncryptionKMS == "":
+		return fmt.Errorf("ENCRYPT_FIELDS exige ENCRYPTION_KMS")
+	case cfg.EncryptionKey == "":
+		return fmt.Errorf("ENCRYPT_FIELDS exige ENCRYPTION_KEY")
+	case cfg.EncryptionKMS == message.KMSLocal && cfg.EncryptionKeyring == "":
+		return fmt.Errorf("ENCRYPTION_KMS=local exige ENCRYPTION_KEYRING_FILE")
+	}
+	// Os atributos e a ordering key vão em texto puro, e a rota escolhida
+	// revela o valor testado, então nenhum deles pode usar um campo cifrado
+	for _, spec := range cfg.Attributes {
+		if field, ok := cfg.encryptedField(spec.Field); ok {
+			return fmt.Errorf("ATTRIBUTES: o atributo %s copia o campo cifrado %s", spec.Name, field)
+		}
+	}
+	if cfg.OrderingKey != "" {
+		key, err := parseKeyTemplate(cfg.OrderingKey)
+		if err != nil {
+			return err
+		}
+		for _, part := range key.parts {
+			if field, ok := cfg.encryptedField(part.field); part.field != "" && ok {
+				return fmt.Errorf("ORDERING_KEY: usa o campo cifrado %s", field)
+			}
+		}
+	}
+	routes, err := loadRoutes(cfg.Routes, cfg.RoutesFile)
+	if err != nil {
+		return err
+	}
+	for _, route := range routes {
+		paths := route.filter.Fields()
+		for path := range route.Match {
+			paths = append(paths, path)
+		}
+		for _, path := range paths {
+			if field, ok := cfg.encryptedField(path); ok {
+				return fmt.Errorf("ROUTES: a rota para %s usa o campo cifrado %s", route.Topic, field)
+			}
+		}
+	}
+	return nil
+}
+
+// encryptedField devolve o campo cifrado que path usa: o próprio campo, um
+// campo dentro dele ou o objeto que contém ele. O caminho vazio é o
+// registro inteiro.
+func (cfg *Config) encryptedField(path string) (string, bool) {
+	for _, field := range cfg.EncryptFields {
+		if path == "" || path == field || strings.HasPrefix(path, field+".") || strings.HasPrefix(field, path+".") {
+			return field, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,199 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SourceSpec é uma das origens de um fan-in (SOURCES). Os campos vazios
+// herdam a configuração principal (SOURCE_TYPE, ENDPOINT_SERVER...), assim
+// como autenticação, paginação e formato.
+type SourceSpec struct {
+	Tag      string            `json:"tag"`
+	Type     string            `json:"type,omitempty"`
+	Endpoint string            `json:"endpoint,omitempty"`
+	Path     string            `json:"path,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+}
+
+// parseSources lê a lista de origens do fan-in
+func parseSources(raw string) ([]SourceSpec, error) {
+	var specs []SourceSpec
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, fmt.Errorf("SOURCES inválido: %w", err)
+	}
+	seen := map[string]bool{}
+	for i, spec := range specs {
+		if spec.Tag == "" {
+			return nil, fmt.Errorf("SOURCES inválido: origem %d sem tag", i)
+		}
+		if seen[spec.Tag] {
+			return nil, fmt.Errorf("SOURCES inválido: tag %q repetida", spec.Tag)
+		}
+		seen[spec.Tag] = true
+	}
+	return specs, nil
+}
+
+// SourceResult resume uma das origens de um fan-in
+type SourceResult struct {
+	FetchStats
+	Selected  uint64 `json:"selected"`
+	Published uint64 `json:"published"`
+	Errors    uint64 `json:"errors"`
+	Error     string `json:"error,omitempty"` // falha na leitura desta origem
+}
+
+// fanIn é implementado pelas origens compostas, que informam de qual origem
+// veio cada registro. done é chamado quando cada origem termina.
+type fanIn interface {
+	Tags() []string
+	FetchTagged(ctx context.Context, fn func(tag string, rec Record) error, done func(tag string, stats FetchStats, err error)) (FetchStats, error)
+}
+
+// multiSource lê várias origens em paralelo, no máximo concurrency por vez,
+// e junta os registros em um único stream
+type multiSource struct {
+	tags        []string
+	sources     []Source
+	urls        map[string]string // source_url de cada origem, pela tag
+	concurrency int
+}
+
+func newMultiSource(cfg *Config) (*multiSource, error) {
+	m := &multiSource{concurrency: cfg.SourceConcurrency, urls: map[string]string{}}
+	if m.concurrency < 1 {
+		m.concurrency = 1
+	}
+	for _, spec := range cfg.Sources {
+		child := *cfg
+		child.Sources = nil
+		if spec.Type != "" {
+			child.SourceType = spec.Type
+		}
+		if spec.Endpoint != "" {
+			child.Endpoint = spec.Endpoint
+		}
+		if spec.Path != "" {
+			child.SourcePath = spec.Path
+		}
+		if len(spec.Params) > 0 {
+			params := make(map[string]string, len(cfg.QueryParams)+len(spec.Params))
+			for k, v := range cfg.QueryParams {
+				params[k] = v
+			}
+			for k, v := range spec.Params {
+				params[k] = v
+			}
+			child.QueryParams = params
+		}
+
+		src, err := NewSource(&child)
+		if err != nil {
+			return nil, fmt.Errorf("origem %s: %w", spec.Tag, err)
+		}
+		m.tags = append(m.tags, spec.Tag)
+		m.urls[spec.Tag] = sourceURL(&child)
+		m.sources = append(m.sources, src)
+	}
+	return m, nil
+}
+
+func (m *multiSource) Tags() []string {
+	return m.tags
+}
+
+func (m *multiSource) Fetch(ctx context.Context, fn func(Record) error) (FetchStats, error) {
+	return m.FetchTagged(ctx, func(_ string, rec Record) error { return fn(rec) }, nil)
+}
+
+type taggedRecord struct {
+	tag string
+	rec Record
+}
+
+type sourceDone struct {
+	i     int
+	stats FetchStats
+	err   error
+}
+
+// FetchTagged lê as origens em paralelo e entrega os registros a fn sempre
+// na goroutine de quem chamou, um por vez. Uma origem com erro não
+// interrompe as demais; o erro devolvido é o de fn, que interrompe todas, ou
+// o da primeira origem da lista que falhou.
+func (m *multiSource) FetchTagged(ctx context.Context, fn func(tag string, rec Record) error, done func(tag string, stats FetchStats, err error)) (FetchStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	records := make(chan taggedRecord)
+	results := make(chan sourceDone, len(m.sources))
+	sem := make(chan struct{}, m.concurrency)
+
+	for i, src := range m.sources {
+		go func(i int, tag string, src Source) {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results <- sourceDone{i: i, err: ctx.Err()}
+				return
+			}
+
+			logrus.Debugf("Fetching source %s", tag)
+			stats, err := src.Fetch(ctx, func(rec Record) error {
+				select {
+				case records <- taggedRecord{tag: tag, rec: rec}:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			results <- sourceDone{i: i, stats: stats, err: err}
+		}(i, m.tags[i], src)
+	}
+
+	var total FetchStats
+	var fnErr error
+	errs := make([]error, len(m.sources))
+	for pending := len(m.sources); pending > 0; {
+		select {
+		case tr := <-records:
+			if fnErr != nil {
+				continue
+			}
+			if err := fn(tr.tag, tr.rec); err != nil {
+				// Para todas as origens; os registros em trânsito são descartados
+				fnErr = err
+				cancel()
+			}
+		case d := <-results:
+			pending--
+			if fnErr != nil && errors.Is(d.err, context.Canceled) {
+				d.err = nil
+			}
+			if d.err != nil {
+				logrus.Errorf("Source %s failed: %v", m.tags[d.i], d.err)
+				errs[d.i] = fmt.Errorf("origem %s: %w", m.tags[d.i], d.err)
+			}
+			total.add(d.stats)
+			if done != nil {
+				done(m.tags[d.i], d.stats, d.err)
+			}
+		}
+	}
+
+	if fnErr != nil {
+		return total, fnErr
+	}
+	for _, err := range errs {
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
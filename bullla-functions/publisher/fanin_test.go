@@ -0,0 +1,85 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseSourcesInvalid(t *testing.T) {
+	for _, raw := range []string{
+		`{"tag": "a"}`,
+		`[{"endpoint": "http://a"}]`,
+		`[{"tag": "a"}, {"tag": "a"}]`,
+	} {
+		if _, err := parseSources(raw); err == nil {
+			t.Errorf("parseSources(%s) aceitou a lista", raw)
+		}
+	}
+}
+
+func TestRunFanIn(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sul":
+			fmt.Fprintf(w, `[{"id": "s1", "regiao": "%s"}, {"id": "s2", "regiao": "%s"}]`, r.URL.Query().Get("regiao"), r.URL.Query().Get("regiao"))
+		case "/norte":
+			fmt.Fprint(w, `[{"id": "n1"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := loadTestConfig(t, map[string]string{
+		"ENDPOINT_SERVER": srv.URL + "/sul",
+		"TOPIC_ID":        "topico",
+		"DRY_RUN":         "true",
+		"SOURCES": fmt.Sprintf(`[{"tag": "sul", "params": {"regiao": "s"}}, {"tag": "norte", "endpoint": "%s/norte"}, {"tag": "leste", "endpoint": "%s/leste"}]`,
+			srv.URL, srv.URL),
+	})
+	p, err := NewPipeline(cfg, newTestClient(t, "topico"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := p.Run(context.Background())
+	// A origem com falha não interrompe as outras, mas a execução termina com o erro dela
+	if err == nil || !strings.Contains(err.Error(), "origem leste") {
+		t.Fatalf("Run = %v, want erro da origem leste", err)
+	}
+
+	var got []string
+	for _, m := range result.Messages {
+		if m.Source != m.Attributes[attrSource] {
+			t.Errorf("%s: source %q, atributo %q", m.ID, m.Source, m.Attributes[attrSource])
+		}
+		if want := srv.URL + "/" + m.Source; !strings.HasPrefix(m.Attributes[attrSourceURL], want) {
+			t.Errorf("%s: source_url %q, want %s", m.ID, m.Attributes[attrSourceURL], want)
+		}
+		got = append(got, m.Source+":"+m.ID)
+	}
+	sort.Strings(got)
+	if want := "[norte:n1 sul:s1 sul:s2]"; fmt.Sprint(got) != want {
+		t.Errorf("mensagens = %v, want %s", got, want)
+	}
+	// Os params da origem vão na query só dela
+	for _, m := range result.Messages {
+		if m.Source == "sul" && !strings.Contains(string(m.Payload), `"regiao":"s"`) {
+			t.Errorf("%s: payload %s, want os params da origem sul", m.ID, m.Payload)
+		}
+	}
+
+	for tag, want := range map[string]uint64{"sul": 2, "norte": 1, "leste": 0} {
+		sr := result.Sources[tag]
+		if sr == nil || uint64(sr.Records) != want || sr.Selected != want {
+			t.Errorf("%s: %+v, want %d registros", tag, sr, want)
+		}
+	}
+	if sr := result.Sources["leste"]; sr == nil || sr.Error == "" {
+		t.Errorf("leste: %+v, want o erro da origem", sr)
+	}
+}
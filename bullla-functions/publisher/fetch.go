@@ -0,0 +1,340 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errNotModified indica que a origem respondeu 304 à requisição condicional
+var errNotModified = errors.New("origem não modificada")
+
+// httpSource lê os registros de ENDPOINT_SERVER, seguindo a paginação configurada
+type httpSource struct {
+	cfg    *Config
+	client *http.Client
+
+	// Leitura incremental: validadores e high-water mark do último checkpoint
+	// e validadores recebidos na primeira página desta execução
+	since        string
+	etag         string
+	lastModified string
+	newETag      string
+	newModified  string
+}
+
+// Resume faz a próxima leitura continuar a partir do checkpoint
+func (s *httpSource) Resume(cp *Checkpoint) {
+	s.since = cp.HighWater
+	s.etag = cp.ETag
+	s.lastModified = cp.LastModified
+}
+
+// Validators devolve o ETag e o Last-Modified recebidos nesta execução
+func (s *httpSource) Validators() (string, string) {
+	return s.newETag, s.newModified
+}
+
+// Fetch busca todas as páginas de ENDPOINT_SERVER e entrega cada registro
+// a fn conforme é decodificado, sem acumular o dataset em memória
+func (s *httpSource) Fetch(ctx context.Context, fn func(Record) error) (FetchStats, error) {
+	cfg := s.cfg
+	var stats FetchStats
+
+	// URL do endpoint
+	if cfg.Endpoint == "" {
+		return stats, fmt.Errorf("ENDPOINT_SERVER is not set")
+	}
+	base, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return stats, fmt.Errorf("ENDPOINT_SERVER inválido: %w", err)
+	}
+	if len(cfg.QueryParams) > 0 {
+		base, _ = url.Parse(withQuery(base, cfg.QueryParams))
+	}
+	if s.since != "" && cfg.SinceField != "" {
+		// A high-water mark vai em todas as páginas
+		base, _ = url.Parse(withQuery(base, map[string]string{cfg.SinceParam: s.since}))
+	}
+
+	// Os validadores de uma página não dizem nada sobre as seguintes, então
+	// a requisição condicional só vale para a origem sem paginação
+	conditional := cfg.Pagination == ""
+
+	page := cfg.PageStart
+	next := firstPageURL(cfg, base)
+	// URLs já buscadas, para não ficar em loop se a origem repetir o cursor
+	seen := map[string]bool{}
+	for next != "" {
+		if cfg.MaxPages > 0 && stats.Pages >= cfg.MaxPages {
+			logrus.Warnf("MAX_PAGES (%d) reached, stopping pagination", cfg.MaxPages)
+			break
+		}
+
+		count, cursor, err := s.fetchPage(ctx, next, conditional, &stats, fn)
+		stats.Records += count
+		if errors.Is(err, errNotModified) {
+			logrus.Infof("Source not modified since last checkpoint")
+			s.newETag, s.newModified = s.etag, s.lastModified
+			return stats, nil
+		}
+		if errors.Is(err, errLimitReached) {
+			// A página foi lida, ainda que só em parte
+			stats.Pages++
+			return stats, err
+		}
+		if err != nil {
+			return stats, fmt.Errorf("página %d: %w", stats.Pages+1, err)
+		}
+		stats.Pages++
+		logrus.Debugf("Page %d: %d records", stats.Pages, count)
+
+		current := next
+		next = ""
+		switch cfg.Pagination {
+		case "page":
+			if count > 0 && count >= cfg.PageSize {
+				page++
+				next = withQuery(base, map[string]string{
+					cfg.PageParam:  strconv.Itoa(page),
+					cfg.LimitParam: strconv.Itoa(cfg.PageSize),
+				})
+			}
+		case "cursor", "link":
+			if cursor != "" {
+				if next, err = resolveCursor(cfg, base, current, cursor); err != nil {
+					return stats, fmt.Errorf("página %d: %w", stats.Pages, err)
+				}
+			}
+		}
+		seen[current] = true
+		if seen[next] {
+			return stats, fmt.Errorf("página %d: a origem repetiu a próxima página (cursor %q)", stats.Pages, cursor)
+		}
+	}
+
+	return stats, nil
+}
+
+func firstPageURL(cfg *Config, base *url.URL) string {
+	switch cfg.Pagination {
+	case "page":
+		return withQuery(base, map[string]string{
+			cfg.PageParam:  strconv.Itoa(cfg.PageStart),
+			cfg.LimitParam: strconv.Itoa(cfg.PageSize),
+		})
+	case "cursor":
+		if cfg.PageSize > 0 {
+			return withQuery(base, map[string]string{cfg.LimitParam: strconv.Itoa(cfg.PageSize)})
+		}
+	}
+	return base.String()
+}
+
+// fetchPage faz o GET de uma página, entrega cada registro a fn e devolve
+// quantos foram lidos e o cursor (campo next ou header Link) para a próxima.
+// O corpo pode ser JSON (array ou envelope), NDJSON ou CSV, comprimido ou
+// não; os bytes lidos são somados em stats. Com conditional a requisição
+// leva os validadores do checkpoint e guarda os recebidos.
+func (s *httpSource) fetchPage(ctx context.Context, pageURL string, conditional bool, stats *FetchStats, fn func(Record) error) (int, string, error) {
+	cfg := s.cfg
+	logrus.Debugf("Fetching URL: %s", pageURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("erro ao criar a requisição: %w", err)
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if conditional {
+		// Requisição condicional com os validadores do último checkpoint
+		if s.etag != "" {
+			req.Header.Set("If-None-Match", s.etag)
+		}
+		if s.lastModified != "" {
+			req.Header.Set("If-Modified-Since", s.lastModified)
+		}
+	}
+
+	// Fazendo a requisição GET, com retentativas
+	resp, err := s.do(ctx, req)
+	if err != nil {
+		return 0, "", fmt.Errorf("erro ao fazer a requisição: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return 0, "", errNotModified
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, "", fmt.Errorf("recebido código de status %d", resp.StatusCode)
+	}
+
+	if conditional {
+		s.newETag = resp.Header.Get("ETag")
+		s.newModified = resp.Header.Get("Last-Modified")
+	}
+
+	// Formato explícito (SOURCE_FORMAT) ou deduzido pelo Content-Type
+	format := cfg.Format
+	if format == "" {
+		format = formatFromContentType(resp.Header.Get("Content-Type"))
+	}
+
+	// Decodificando o corpo da resposta em streaming
+	logrus.Debugf("Request successful, decoding %s body", format)
+	cursorPath := ""
+	if cfg.Pagination == "cursor" {
+		cursorPath = cfg.CursorField
+	}
+	body, err := newResponseBody(resp, cfg.MaxBodyBytes)
+	if err != nil {
+		return 0, "", err
+	}
+	defer body.Close()
+
+	count, cursor, err := decodeBody(body, format, cfg, cursorPath, fn)
+	stats.WireBytes += body.wire.n
+	stats.Bytes += body.decoded.n
+	logrus.Debugf("Page body: %d bytes, %d on the wire (%s)", body.decoded.n, body.wire.n, encodingName(resp))
+	if errors.Is(err, ErrBodyTooLarge) {
+		// O limite pode estourar no meio de um registro; o erro de parse só
+		// confunde, então o erro devolvido é o do limite, com o valor
+		err = fmt.Errorf("%w (%d bytes)", ErrBodyTooLarge, cfg.MaxBodyBytes)
+	}
+	if cfg.Pagination == "link" {
+		cursor = nextLink(resp.Header.Values("Link"))
+	}
+	return count, cursor, err
+}
+
+// cursorValue aceita o cursor como string, número ou null
+func cursorValue(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	switch c := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return c, nil
+	case json.Number:
+		return c.String(), nil
+	default:
+		return "", fmt.Errorf("tipo não suportado %T", v)
+	}
+}
+
+// resolveCursor transforma o cursor recebido na URL da próxima página.
+// O cursor pode ser uma URL (absoluta ou relativa) ou um token opaco. Uma
+// URL com esquema ou host diferente de ENDPOINT_SERVER é recusada: as
+// credenciais da origem iriam junto para o outro servidor.
+func resolveCursor(cfg *Config, base *url.URL, current, cursor string) (string, error) {
+	if cfg.Pagination == "cursor" && !isURLRef(cursor) {
+		params := map[string]string{cfg.CursorParam: cursor}
+		if cfg.PageSize > 0 {
+			params[cfg.LimitParam] = strconv.Itoa(cfg.PageSize)
+		}
+		return withQuery(base, params), nil
+	}
+
+	cur, err := url.Parse(current)
+	if err != nil {
+		return "", nil
+	}
+	ref, err := url.Parse(cursor)
+	if err != nil {
+		logrus.Warnf("Ignoring invalid next page URL %q: %v", cursor, err)
+		return "", nil
+	}
+	next := cur.ResolveReference(ref)
+	if !strings.EqualFold(next.Scheme, base.Scheme) || !strings.EqualFold(next.Host, base.Host) {
+		return "", fmt.Errorf("a próxima página %q está fora de ENDPOINT_SERVER (%s://%s)", cursor, base.Scheme, base.Host)
+	}
+	return next.String(), nil
+}
+
+func isURLRef(s string) bool {
+	for _, prefix := range []string{"http://", "https://", "/", "?"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextLink extrai o rel="next" de um header Link (RFC 5988)
+func nextLink(headers []string) string {
+	for _, header := range headers {
+		for _, link := range splitOutside(header, ',') {
+			target, params, _ := strings.Cut(link, ">")
+			target = strings.TrimSpace(target)
+			if !strings.HasPrefix(target, "<") {
+				continue
+			}
+			for _, param := range splitOutside(params, ';') {
+				key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target[1:]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// splitOutside divide s em sep, ignorando os separadores dentro de <...>
+// ou de aspas: a URL e os parâmetros de um header Link podem conter vírgulas
+func splitOutside(s string, sep byte) []string {
+	var parts []string
+	inURL, inQuote := false, false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inQuote:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inQuote = false
+			}
+		case inURL:
+			inURL = c != '>'
+		case c == '<':
+			inURL = true
+		case c == '"':
+			inQuote = true
+		case c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func withQuery(base *url.URL, params map[string]string) string {
+	u := *base
+	q := u.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,198 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    string
+	}{
+		{"só next", []string{`<https://api.example.com/items?page=2>; rel="next"`}, "https://api.example.com/items?page=2"},
+		{"vírgulas na URL", []string{`<https://x/items?ids=1,2,3>; rel="prev", <https://x/items?ids=4,5>; rel="next"`}, "https://x/items?ids=4,5"},
+		{"vírgula e ponto e vírgula entre aspas", []string{`<https://x/b>; title="a, b; c"; rel="next last"`}, "https://x/b"},
+		{"rel sem aspas e em maiúsculas", []string{`<https://x/c>; REL=Next`}, "https://x/c"},
+		{"em headers separados", []string{`<https://x/a>; rel="prev"`, `</items?page=3>; rel="next"`}, "/items?page=3"},
+		{"sem next", []string{`<https://x/a>; rel="prev", <https://x/z>; rel="last"`}, ""},
+		{"sem header", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextLink(tt.headers); got != tt.want {
+				t.Errorf("nextLink = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// loadTestConfig carrega a configuração das variáveis informadas
+func loadTestConfig(t *testing.T, env map[string]string) *Config {
+	t.Helper()
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestFetchLinkPagination(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		switch page {
+		case "":
+			w.Header().Set("Link", `</items?page=2&ids=1,2>; rel="next"`)
+		case "2":
+			w.Header().Set("Link", `</items?page=3>; rel="next"`)
+		}
+		fmt.Fprintf(w, `[{"id": "%s-a"}, {"id": "%s-b"}]`, page, page)
+	}))
+	defer srv.Close()
+
+	cfg := loadTestConfig(t, map[string]string{"ENDPOINT_SERVER": srv.URL + "/items", "PAGINATION": "link"})
+	src, err := NewSource(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := src.Fetch(context.Background(), func(Record) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pages != 3 || stats.Records != 6 {
+		t.Errorf("stats = %+v, want 3 páginas e 6 registros", stats)
+	}
+}
+
+func TestFetchRepeatedCursor(t *testing.T) {
+	tests := []struct {
+		pagination string
+		handler    http.HandlerFunc
+	}{
+		{"link", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Link", `</items?page=2>; rel="next"`)
+			fmt.Fprint(w, `{"items": [{"id": 1}]}`)
+		}},
+		{"cursor", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"items": [{"id": 1}], "next": "abc"}`)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pagination, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			cfg := loadTestConfig(t, map[string]string{
+				"ENDPOINT_SERVER": srv.URL + "/items",
+				"PAGINATION":      tt.pagination,
+				"RECORDS_PATH":    "items",
+				"MAX_PAGES":       "0",
+			})
+			src, err := NewSource(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats, err := src.Fetch(context.Background(), func(Record) error { return nil })
+			if err == nil || !strings.Contains(err.Error(), "repetiu") {
+				t.Fatalf("Fetch = %v, want erro de página repetida", err)
+			}
+			if stats.Pages > 3 {
+				t.Errorf("%d páginas lidas antes de parar", stats.Pages)
+			}
+		})
+	}
+}
+
+func TestFetchPaginatedNotConditional(t *testing.T) {
+	var conditional []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `</items?page=2>; rel="next"`)
+		}
+		fmt.Fprint(w, `[{"id": 1}]`)
+	}))
+	defer srv.Close()
+
+	cfg := loadTestConfig(t, map[string]string{"ENDPOINT_SERVER": srv.URL + "/items", "PAGINATION": "link"})
+	src, err := NewSource(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// O ETag da primeira página não vale pelas seguintes
+	src.(resumable).Resume(&Checkpoint{ETag: `"v1"`})
+	stats, err := src.Fetch(context.Background(), func(Record) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pages != 2 || stats.Records != 2 {
+		t.Errorf("stats = %+v, want 2 páginas e 2 registros", stats)
+	}
+	if want := []string{"", ""}; fmt.Sprint(conditional) != fmt.Sprint(want) {
+		t.Errorf("If-None-Match = %q, want %q", conditional, want)
+	}
+	if etag, modified := src.(resumable).Validators(); etag != "" || modified != "" {
+		t.Errorf("Validators = %q, %q, want vazios", etag, modified)
+	}
+}
+
+func TestFetchCrossHostNextPage(t *testing.T) {
+	var leaked []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = append(leaked, r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"items": []}`)
+	}))
+	defer other.Close()
+
+	tests := []struct {
+		pagination string
+		handler    http.HandlerFunc
+	}{
+		{"link", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/items?page=2>; rel="next"`, other.URL))
+			fmt.Fprint(w, `{"items": [{"id": 1}]}`)
+		}},
+		{"cursor", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"items": [{"id": 1}], "next": "%s/items?page=2"}`, other.URL)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pagination, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			cfg := loadTestConfig(t, map[string]string{
+				"ENDPOINT_SERVER": srv.URL + "/items",
+				"PAGINATION":      tt.pagination,
+				"RECORDS_PATH":    "items",
+				"SOURCE_AUTH":     "basic",
+				"SOURCE_USERNAME": "usuario",
+				"SOURCE_PASSWORD": "segredo",
+			})
+			src, err := NewSource(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = src.Fetch(context.Background(), func(Record) error { return nil })
+			if err == nil || !strings.Contains(err.Error(), "fora de ENDPOINT_SERVER") {
+				t.Fatalf("Fetch = %v, want erro de página fora de ENDPOINT_SERVER", err)
+			}
+		})
+	}
+	if len(leaked) > 0 {
+		t.Errorf("o outro host recebeu %d requisições (Authorization %q)", len(leaked), leaked)
+	}
+}
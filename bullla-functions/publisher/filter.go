@@ -0,0 +1,195 @@
+package publisher
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+)
+
+// Filter é uma expressão CEL avaliada sobre cada payload. O registro fica
+// disponível na variável record, por exemplo:
+//
+//	record.status == "ativo" && record.amount > 100
+type Filter struct {
+	expr   string
+	prg    cel.Program
+	fields []string
+}
+
+// maxCompiledFilters limita o cache de filtros. As expressões vêm também
+// das requisições, então o cache descarta as usadas há mais tempo.
+const maxCompiledFilters = 256
+
+// As expressões são compiladas uma vez por instância e reaproveitadas entre
+// invocações. filterLRU guarda os filtros do mais ao menos usado.
+var (
+	filtersMu       sync.Mutex
+	compiledFilters = map[string]*list.Element{}
+	filterLRU       = list.New()
+	filterEnv       *cel.Env
+)
+
+// compileFilter compila (ou devolve do cache) a expressão informada
+func compileFilter(expr string) (*Filter, error) {
+	filtersMu.Lock()
+	defer filtersMu.Unlock()
+	if e, ok := compiledFilters[expr]; ok {
+		filterLRU.MoveToFront(e)
+		return e.Value.(*Filter), nil
+	}
+
+	if filterEnv == nil {
+		env, err := cel.NewEnv(
+			cel.Variable("record", cel.MapType(cel.StringType, cel.DynType)),
+			cel.CrossTypeNumericComparisons(true),
+		)
+		if err != nil {
+			return nil, err
+		}
+		filterEnv = env
+	}
+
+	ast, iss := filterEnv.Compile(expr)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("filtro inválido: %w", iss.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("filtro inválido: a expressão deve ser booleana, não %s", ast.OutputType())
+	}
+	prg, err := filterEnv.Program(ast, cel.EvalOptions(cel.OptOptimize))
+	if err != nil {
+		return nil, fmt.Errorf("filtro inválido: %w", err)
+	}
+
+	f := &Filter{expr: expr, prg: prg, fields: recordFields(ast.NativeRep().Expr())}
+	compiledFilters[expr] = filterLRU.PushFront(f)
+	if filterLRU.Len() > maxCompiledFilters {
+		oldest := filterLRU.Remove(filterLRU.Back()).(*Filter)
+		delete(compiledFilters, oldest.expr)
+	}
+	return f, nil
+}
+
+// Fields devolve os caminhos dos campos de record usados na expressão
+// (record.a.b ou record["a"]["b"]). Um índice calculado conta como o objeto
+// inteiro e o caminho vazio indica o registro inteiro.
+func (f *Filter) Fields() []string {
+	if f == nil {
+		return nil
+	}
+	return f.fields
+}
+
+// recordFields coleta os caminhos de record na expressão, sem os prefixos
+// que fazem parte de um caminho maior
+func recordFields(expr celast.Expr) []string {
+	paths := map[int64]string{}
+	inner := map[int64]bool{}
+	celast.PreOrderVisit(expr, celast.NewExprVisitor(func(e celast.Expr) {
+		if path, operand, ok := recordPath(e); ok {
+			paths[e.ID()] = path
+			if operand != nil {
+				inner[operand.ID()] = true
+			}
+		}
+	}))
+	var fields []string
+	for id, path := range paths {
+		if !inner[id] {
+			fields = append(fields, path)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// recordPath devolve o caminho de e quando é record ou um acesso a um campo
+// dele, junto com o operando do acesso
+func recordPath(e celast.Expr) (string, celast.Expr, bool) {
+	var operand celast.Expr
+	var field string
+	switch e.Kind() {
+	case celast.IdentKind:
+		return "", nil, e.AsIdent() == "record"
+	case celast.SelectKind:
+		operand, field = e.AsSelect().Operand(), e.AsSelect().FieldName()
+	case celast.CallKind:
+		call := e.AsCall()
+		if call.FunctionName() != operators.Index || len(call.Args()) != 2 {
+			return "", nil, false
+		}
+		operand = call.Args()[0]
+		if key := call.Args()[1]; key.Kind() == celast.LiteralKind {
+			if s, ok := key.AsLiteral().Value().(string); ok {
+				field = s
+			}
+		}
+	default:
+		return "", nil, false
+	}
+	path, _, ok := recordPath(operand)
+	if !ok {
+		return "", nil, false
+	}
+	if field == "" {
+		// Índice calculado: vale o objeto inteiro
+		return path, operand, true
+	}
+	if path != "" {
+		field = path + "." + field
+	}
+	return field, operand, true
+}
+
+// Match avalia a expressão sobre rec. Um filtro nil aceita tudo.
+func (f *Filter) Match(rec Record) (bool, error) {
+	if f == nil {
+		return true, nil
+	}
+	out, _, err := f.prg.Eval(map[string]interface{}{"record": celValue(map[string]interface{}(rec))})
+	if err != nil {
+		return false, err
+	}
+	match, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("a expressão devolveu %v, não um booleano", out.Value())
+	}
+	return match, nil
+}
+
+// celValue converte os valores do registro para tipos que o CEL conhece:
+// json.Number vira int ou double e os objetos aninhados viram mapas
+func celValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case Record:
+		return celValue(map[string]interface{}(v))
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = celValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = celValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
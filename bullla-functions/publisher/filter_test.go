@@ -0,0 +1,85 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	rec := Record{
+		"status":   "ativo",
+		"amount":   json.Number("150"),
+		"rate":     json.Number("0.5"),
+		"customer": Record{"region": "sul"},
+		"tags":     []interface{}{"a", "b"},
+	}
+	tests := []struct {
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{`record.status == "ativo" && record.amount > 100`, true, false},
+		{`record.amount > 100.5`, true, false},
+		{`record.rate < 1`, true, false},
+		{`record.customer.region == "norte"`, false, false},
+		{`has(record.customer.id)`, false, false},
+		{`"b" in record.tags`, true, false},
+		// Campo ausente: a avaliação falha e o registro não casa
+		{`record.missing == 1`, false, true},
+	}
+	for _, tt := range tests {
+		f, err := compileFilter(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		got, err := f.Match(rec)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%s: Match = %v, %v; want %v, erro %v", tt.expr, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	var f *Filter
+	if ok, err := f.Match(rec); !ok || err != nil {
+		t.Errorf("filtro nil: Match = %v, %v; want true", ok, err)
+	}
+}
+
+func TestCompileFilterInvalid(t *testing.T) {
+	for _, expr := range []string{`record.status ==`, `record.amount + 1`, `outro.campo == 1`} {
+		if _, err := compileFilter(expr); err == nil {
+			t.Errorf("%s: compileFilter aceitou a expressão", expr)
+		}
+	}
+}
+
+func TestCompileFilterCache(t *testing.T) {
+	first, err := compileFilter(`record.id == 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := compileFilter(`record.id == 0`); again != first {
+		t.Error("a expressão foi compilada de novo")
+	}
+
+	// Passando do limite, sai a expressão usada há mais tempo
+	for i := 1; i <= maxCompiledFilters; i++ {
+		if i == maxCompiledFilters/2 {
+			compileFilter(`record.id == 0`)
+		}
+		if _, err := compileFilter(fmt.Sprintf(`record.id == %d`, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filtersMu.Lock()
+	size := filterLRU.Len()
+	_, kept := compiledFilters[`record.id == 0`]
+	_, evicted := compiledFilters[`record.id == 1`]
+	filtersMu.Unlock()
+	if size != maxCompiledFilters || len(compiledFilters) != size {
+		t.Errorf("cache com %d filtros (%d no mapa), want %d", size, len(compiledFilters), maxCompiledFilters)
+	}
+	if !kept || evicted {
+		t.Errorf("usada recentemente no cache = %v, mais antiga = %v; want true, false", kept, evicted)
+	}
+}
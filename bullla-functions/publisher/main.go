@@ -2,13 +2,10 @@ package publisher
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/pubsub"
@@ -31,114 +28,84 @@ func createClient() {
 	}
 }
 
+// Configuração das variáveis de ambiente, carregada e validada uma vez por
+// instância; cada requisição trabalha sobre uma cópia
+var (
+	baseCfg    *Config
+	baseCfgErr error
+	cfgOnce    sync.Once
+)
+
+func loadBaseConfig() {
+	baseCfg, baseCfgErr = LoadConfig()
+	if baseCfgErr != nil {
+		return
+	}
+	logrus.Infof("Publish settings: %s", baseCfg.Publish)
+}
+
 func init() {
 	//
 	//runtime.GOMAXPROCS(2)
 	// Registrando HTTP Function
 	functions.HTTP("Main", PublishMessage)
-}
 
-type Message struct {
-	ID      string `json:"id"`
-	Message string `json:"message"`
+	// Servindo a function (Cloud Functions ou local), uma configuração
+	// inválida impede a instância de subir
+	if os.Getenv("FUNCTION_TARGET") != "" {
+		cfgOnce.Do(loadBaseConfig)
+		if baseCfgErr != nil {
+			logrus.Fatalf("Invalid configuration: %v", baseCfgErr)
+		}
+	}
 }
 
-func fetchMessages() ([]Message, error) {
-	// URL do endpoint
-	url := os.Getenv("ENDPOINT_SERVER")
-	if url == "" {
-		return nil, fmt.Errorf("ENDPOINT_SERVER is not set")
-	}
-	logrus.Debugf("Fetching URL: %s", url)
+func PublishMessage(w http.ResponseWriter, r *http.Request) {
+	logrus.SetLevel(logrus.DebugLevel)
 
-	// Fazendo a requisição GET
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("erro ao fazer a requisição: %w", err)
+	cfgOnce.Do(loadBaseConfig)
+	if baseCfgErr != nil {
+		ErrorReport(http.StatusInternalServerError, fmt.Errorf("configuração inválida: %w", baseCfgErr)).Write(w)
+		return
 	}
-	defer resp.Body.Close()
+	// Apply substitui os mapas em vez de alterá-los, então a cópia rasa basta
+	cfg := *baseCfg
 
-	// Lendo o corpo da resposta
-	logrus.Debug("Request successful, reading body")
-	body, err := io.ReadAll(resp.Body)
+	// Parâmetros opcionais desta execução, enviados no corpo do POST
+	req, err := ParseRunRequest(r)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao ler o corpo da resposta: %w", err)
-	}
-
-	// Parse do JSON recebido
-	var messages []Message
-	if err := json.Unmarshal(body, &messages); err != nil {
-		return nil, fmt.Errorf("erro ao fazer parse do JSON: %w", err)
+		ErrorReport(http.StatusBadRequest, fmt.Errorf("requisição inválida: %w", err)).Write(w)
+		return
 	}
+	req.Apply(&cfg)
 
-	return messages, nil
-}
-
-func PublishMessage(w http.ResponseWriter, r *http.Request) {
-	logrus.SetLevel(logrus.DebugLevel)
-
-	var messages []Message
-	var topicID string = os.Getenv("TOPIC_ID")
-	if topicID == "" {
-		http.Error(w, "TOPIC_ID is not set", http.StatusInternalServerError)
+	if cfg.TopicID == "" {
+		ErrorReport(http.StatusInternalServerError, fmt.Errorf("TOPIC_ID is not set")).Write(w)
 		return
 	}
 
 	once.Do(createClient)
 
-	messages, err := fetchMessages()
+	p, err := NewPipeline(&cfg, client)
 	if err != nil {
-		logrus.Fatalf("Falha ao recuperar mensagens: %v", err)
-		http.Error(w, fmt.Sprintf("Falha ao recuperar mensagens: %v", err), http.StatusInternalServerError)
+		ErrorReport(http.StatusInternalServerError, fmt.Errorf("configuração inválida: %w", err)).Write(w)
 		return
 	}
+	// Os tópicos ficam abertos para as próximas invocações da instância
+
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(10)*time.Minute)
 	defer cancel()
 
-	t := client.Topic(topicID)
-	t.PublishSettings.FlowControlSettings = pubsub.FlowControlSettings{
-		MaxOutstandingMessages: 100,
-		MaxOutstandingBytes:    10 * 1024 * 1024,
-		LimitExceededBehavior:  pubsub.FlowControlBlock,
-	}
-
-	var wg sync.WaitGroup
-	var totalErrors uint64
-
-	numMsgs := len(messages)
-	for i, msg := range messages {
-		wg.Add(1)
-		messageJSON, err := json.Marshal(msg)
-		if err != nil {
-			logrus.Errorf("Erro ao converter mensagem para JSON: %v", err)
-			wg.Done()
-			atomic.AddUint64(&totalErrors, 1)
-			continue
-		}
-
-		result := t.Publish(ctx, &pubsub.Message{
-			Data: []byte(messageJSON),
-		})
-
-		go func(i int, res *pubsub.PublishResult) {
-			defer wg.Done()
-			_, err := res.Get(ctx)
-			if err != nil {
-				logrus.Errorf("Failed to publish message %d: %v", i, err)
-				atomic.AddUint64(&totalErrors, 1)
-				return
-			}
-			logrus.Infof("Successfully published message %d", i)
-		}(i, result)
+	// O resultado de cada registro é coletado pelo pipeline e a resposta é
+	// escrita uma vez só, no final
+	result, err := p.Run(ctx)
+	if err != nil {
+		logrus.Errorf("Falha ao recuperar mensagens: %v", err)
 	}
-
-	wg.Wait()
-
-	if totalErrors > 0 {
-		http.Error(w, fmt.Sprintf("%d of %d messages did not publish successfully", totalErrors, numMsgs), http.StatusInternalServerError)
-		return
+	report := NewReport(result, err)
+	if result != nil {
+		logrus.Infof("Run %s finished with status %d: %d of %d records published, %d errors, %d rejected",
+			result.RunID, report.Status, result.Published, result.Records, result.Errors, result.RejectedCount())
 	}
-
-	fmt.Fprint(w, "All messages published successfully")
-	logrus.Debug("All messages published successfully")
+	report.Write(w)
 }
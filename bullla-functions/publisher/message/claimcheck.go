@@ -0,0 +1,260 @@
+package message
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AttrClaimCheck marca as mensagens cujo payload é uma referência ao blob
+// com o conteúdo. Os demais atributos (content-encoding, inclusive) valem
+// para o conteúdo do blob.
+const AttrClaimCheck = "claim_check"
+
+// ClaimCheckVersion é o formato da referência publicada
+const ClaimCheckVersion = "v1"
+
+// BlobStoreFile é o BlobStore de arquivos locais
+const BlobStoreFile = "file"
+
+// ErrBlobNotFound é devolvido por BlobStore.Get quando o blob não existe
+var ErrBlobNotFound = errors.New("blob não encontrado")
+
+// BlobStore guarda os payloads grandes demais para o Pub/Sub (claim-check)
+type BlobStore interface {
+	// Put grava data sob key e devolve a URI do blob
+	Put(ctx context.Context, key string, data []byte) (string, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	// Cleanup remove os blobs gravados antes de before e devolve quantos
+	Cleanup(ctx context.Context, before time.Time) (int, error)
+}
+
+// ClaimCheckRef é o payload publicado no lugar do conteúdo
+type ClaimCheckRef struct {
+	Key    string `json:"key"`
+	URI    string `json:"uri"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// OpenBlobStore abre o BlobStore pelo tipo ("" devolve nil, sem claim-check).
+// O de arquivos grava em dir.
+func OpenBlobStore(store, dir string) (BlobStore, error) {
+	switch store {
+	case "":
+		return nil, nil
+	case BlobStoreFile:
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("diretório dos blobs inválido: %w", err)
+		}
+		return &fileBlobStore{dir: abs}, nil
+	default:
+		return nil, fmt.Errorf("BlobStore inválido: %q", store)
+	}
+}
+
+// NewClaimCheckRef monta a referência publicada no lugar de data
+func NewClaimCheckRef(key, uri string, data []byte) ([]byte, error) {
+	sum := sha256.Sum256(data)
+	return json.Marshal(ClaimCheckRef{Key: key, URI: uri, Size: len(data), SHA256: hex.EncodeToString(sum[:])})
+}
+
+// ResolveClaimCheck lê o blob referenciado pelo payload de uma mensagem com o
+// atributo claim_check e confere o tamanho e o checksum
+func ResolveClaimCheck(ctx context.Context, blobs BlobStore, payload []byte) ([]byte, error) {
+	var ref ClaimCheckRef
+	if err := json.Unmarshal(payload, &ref); err != nil {
+		return nil, fmt.Errorf("referência de claim-check inválida: %w", err)
+	}
+	if ref.Key == "" {
+		return nil, fmt.Errorf("referência de claim-check sem key")
+	}
+	data, err := blobs.Get(ctx, ref.Key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o blob %s: %w", ref.Key, err)
+	}
+	sum := sha256.Sum256(data)
+	if len(data) != ref.Size || hex.EncodeToString(sum[:]) != ref.SHA256 {
+		return nil, fmt.Errorf("blob %s não confere com a referência (tamanho ou sha256)", ref.Key)
+	}
+	return data, nil
+}
+
+// fileBlobStore guarda cada blob como um arquivo em dir
+type fileBlobStore struct {
+	dir string
+}
+
+func (s *fileBlobStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" || clean != key {
+		return "", fmt.Errorf("key de blob inválida: %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *fileBlobStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+
+	// Mesmo esquema do checkpoint: temporário e rename, para nunca deixar
+	// um blob pela metade
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".blob-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return "", err
+	}
+	return "file://" + filepath.ToSlash(p), nil
+}
+
+func (s *fileBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return data, err
+}
+
+func (s *fileBlobStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *fileBlobStore) Cleanup(ctx context.Context, before time.Time) (int, error) {
+	removed := 0
+	var dirs []string
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != s.dir {
+				dirs = append(dirs, p)
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(before) {
+			if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	// Remove os diretórios que ficaram vazios, dos mais fundos para cima;
+	// os que ainda têm arquivos falham no Remove e ficam
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+	return removed, err
+}
+
+// ObjectInfo descreve um objeto listado em um ObjectStore
+type ObjectInfo struct {
+	Name    string
+	Created time.Time
+}
+
+// ObjectStore é o formato de um bucket como o do Cloud Storage: objetos
+// identificados por bucket e nome. Um adaptador sobre o client do Cloud
+// Storage (Bucket(b).Object(name).NewWriter/NewReader/Delete e
+// Bucket(b).Objects) basta para guardar os blobs no GCS. Read e Delete
+// devolvem ErrBlobNotFound quando o objeto não existe.
+type ObjectStore interface {
+	Write(ctx context.Context, bucket, name string, data []byte) error
+	Read(ctx context.Context, bucket, name string) ([]byte, error)
+	Delete(ctx context.Context, bucket, name string) error
+	List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error)
+}
+
+// NewObjectBlobStore guarda os blobs como objetos do bucket, sob prefix. Em
+// vez do Cleanup, uma regra de ciclo de vida do bucket (idade do objeto)
+// pode remover os blobs vencidos.
+func NewObjectBlobStore(objects ObjectStore, bucket, prefix string) BlobStore {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &objectBlobStore{objects: objects, bucket: bucket, prefix: prefix}
+}
+
+type objectBlobStore struct {
+	objects ObjectStore
+	bucket  string
+	prefix  string
+}
+
+func (s *objectBlobStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	if err := s.objects.Write(ctx, s.bucket, s.prefix+key, data); err != nil {
+		return "", err
+	}
+	return "gs://" + s.bucket + "/" + s.prefix + key, nil
+}
+
+func (s *objectBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.objects.Read(ctx, s.bucket, s.prefix+key)
+}
+
+func (s *objectBlobStore) Delete(ctx context.Context, key string) error {
+	return s.objects.Delete(ctx, s.bucket, s.prefix+key)
+}
+
+func (s *objectBlobStore) Cleanup(ctx context.Context, before time.Time) (int, error) {
+	objects, err := s.objects.List(ctx, s.bucket, s.prefix)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, obj := range objects {
+		if !obj.Created.Before(before) {
+			continue
+		}
+		if err := s.objects.Delete(ctx, s.bucket, obj.Name); err != nil && !errors.Is(err, ErrBlobNotFound) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
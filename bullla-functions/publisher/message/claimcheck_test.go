@@ -0,0 +1,107 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileBlobStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	blobs, err := OpenBlobStore(BlobStoreFile, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri, err := blobs.Put(ctx, "run/1", []byte("conteúdo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "file://" + filepath.ToSlash(filepath.Join(dir, "run", "1")); uri != want {
+		t.Errorf("Put = %q, want %q", uri, want)
+	}
+	if data, err := blobs.Get(ctx, "run/1"); err != nil || string(data) != "conteúdo" {
+		t.Errorf("Get = %q, %v", data, err)
+	}
+	// A key não sai do diretório dos blobs
+	for _, key := range []string{"../fora", "/abs", "run/../1", ""} {
+		if _, err := blobs.Put(ctx, key, []byte("x")); err == nil {
+			t.Errorf("Put(%q) aceitou a key", key)
+		}
+	}
+
+	if err := blobs.Delete(ctx, "run/1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blobs.Get(ctx, "run/1"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Get depois do Delete = %v, want ErrBlobNotFound", err)
+	}
+	if err := blobs.Delete(ctx, "run/1"); err != nil {
+		t.Errorf("Delete de um blob removido = %v", err)
+	}
+}
+
+func TestFileBlobStoreCleanup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	blobs, _ := OpenBlobStore(BlobStoreFile, dir)
+	for _, key := range []string{"velho/1", "velho/2", "novo/1"} {
+		if _, err := blobs.Put(ctx, key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"1", "2"} {
+		if err := os.Chtimes(filepath.Join(dir, "velho", name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := blobs.Cleanup(ctx, time.Now().Add(-time.Minute))
+	if err != nil || n != 2 {
+		t.Fatalf("Cleanup = %d, %v; want 2", n, err)
+	}
+	// O diretório vazio sai junto; o outro fica
+	if _, err := os.Stat(filepath.Join(dir, "velho")); !os.IsNotExist(err) {
+		t.Errorf("diretório velho: %v, want removido", err)
+	}
+	if _, err := blobs.Get(ctx, "novo/1"); err != nil {
+		t.Errorf("novo/1: %v", err)
+	}
+}
+
+func TestResolveClaimCheck(t *testing.T) {
+	ctx := context.Background()
+	blobs, _ := OpenBlobStore(BlobStoreFile, t.TempDir())
+	data := []byte(strings.Repeat("payload grande ", 100))
+	uri, err := blobs.Put(ctx, "run/blob", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := NewClaimCheckRef("run/blob", uri, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ResolveClaimCheck(ctx, blobs, ref)
+	if err != nil || string(got) != string(data) {
+		t.Fatalf("ResolveClaimCheck = %d bytes, %v", len(got), err)
+	}
+
+	// Um blob trocado depois da publicação não passa no checksum
+	if _, err := blobs.Put(ctx, "run/blob", []byte(strings.Repeat("payload trocado", 100))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResolveClaimCheck(ctx, blobs, ref); err == nil {
+		t.Error("ResolveClaimCheck aceitou um blob diferente da referência")
+	}
+	for _, payload := range []string{`não é json`, `{"uri": "x"}`, `{"key": "run/ausente"}`} {
+		if _, err := ResolveClaimCheck(ctx, blobs, []byte(payload)); err == nil {
+			t.Errorf("ResolveClaimCheck(%s) aceitou a referência", payload)
+		}
+	}
+}
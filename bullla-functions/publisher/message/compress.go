@@ -0,0 +1,109 @@
+package message
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressões do payload. O consumidor descobre qual foi usada pelo
+// atributo content-encoding.
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+
+	AttrContentEncoding = "content-encoding"
+)
+
+// MaxDecompressedSize limita o tamanho do payload depois de descomprimido
+const MaxDecompressedSize = 64 << 20
+
+// O encoder zstd é criado uma vez por instância; EncodeAll pode ser chamado
+// em paralelo
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdErr     error
+)
+
+// ValidateCompression confere o nome da compressão ("" = sem compressão)
+func ValidateCompression(encoding string) error {
+	switch encoding {
+	case "", Gzip, Zstd:
+		return nil
+	default:
+		return fmt.Errorf("deve ser %s ou %s", Gzip, Zstd)
+	}
+}
+
+// Compress comprime o payload com encoding. Devolve false quando o
+// resultado não fica menor que o original, caso em que o payload vai sem
+// compressão.
+func Compress(data []byte, encoding string) ([]byte, bool, error) {
+	var out []byte
+	switch encoding {
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, false, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, false, err
+		}
+		out = buf.Bytes()
+	case Zstd:
+		zstdOnce.Do(func() {
+			zstdEncoder, zstdErr = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		})
+		if zstdErr != nil {
+			return nil, false, zstdErr
+		}
+		out = zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2))
+	default:
+		return data, false, nil
+	}
+	if len(out) >= len(data) {
+		return data, false, nil
+	}
+	return out, true, nil
+}
+
+// Decompress descomprime o payload conforme o atributo content-encoding
+// (vazio = sem compressão), até MaxDecompressedSize bytes
+func Decompress(data []byte, encoding string) ([]byte, error) {
+	var r io.Reader
+	switch encoding {
+	case "", "identity":
+		return data, nil
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case Zstd:
+		zr, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("content-encoding não suportado: %q", encoding)
+	}
+
+	out, err := io.ReadAll(io.LimitReader(r, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > MaxDecompressedSize {
+		return nil, fmt.Errorf("payload descomprimido maior que %d bytes", MaxDecompressedSize)
+	}
+	return out, nil
+}
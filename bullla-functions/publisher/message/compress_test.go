@@ -0,0 +1,68 @@
+package message
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	payload := []byte(strings.Repeat(`{"id": 1, "name": "registro"}`, 50))
+	for _, encoding := range []string{Gzip, Zstd} {
+		t.Run(encoding, func(t *testing.T) {
+			data, ok, err := Compress(payload, encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok || len(data) >= len(payload) {
+				t.Fatalf("Compress = %d bytes (ok=%v), want menor que %d", len(data), ok, len(payload))
+			}
+			got, err := Decompress(data, encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Errorf("Decompress = %s, want o payload original", got)
+			}
+		})
+	}
+}
+
+func TestCompressNotSmaller(t *testing.T) {
+	// Um payload curto cresce com o cabeçalho da compressão e vai como está
+	payload := []byte(`{"id":1}`)
+	for _, encoding := range []string{Gzip, Zstd, ""} {
+		data, ok, err := Compress(payload, encoding)
+		if err != nil || ok || !bytes.Equal(data, payload) {
+			t.Errorf("%q: Compress = %q, %v, %v; want o payload sem compressão", encoding, data, ok, err)
+		}
+	}
+}
+
+func TestDecompressInvalid(t *testing.T) {
+	if _, err := Decompress([]byte("x"), "br"); err == nil {
+		t.Error("Decompress aceitou content-encoding br")
+	}
+	if _, err := Decompress([]byte("não é gzip"), Gzip); err == nil {
+		t.Error("Decompress aceitou um gzip inválido")
+	}
+	// O limite protege o consumidor de uma bomba de descompressão
+	big, _, err := Compress(make([]byte, MaxDecompressedSize+1), Zstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decompress(big, Zstd); err == nil {
+		t.Error("Decompress passou de MaxDecompressedSize")
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	for _, encoding := range []string{"", Gzip, Zstd} {
+		if err := ValidateCompression(encoding); err != nil {
+			t.Errorf("%q: %v", encoding, err)
+		}
+	}
+	if err := ValidateCompression("br"); err == nil {
+		t.Error("ValidateCompression aceitou br")
+	}
+}
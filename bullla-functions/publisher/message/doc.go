@@ -0,0 +1,6 @@
+// Package message reúne o formato das mensagens publicadas no Pub/Sub,
+// usado dos dois lados: compressão do payload, claim-check, codificação por
+// schema (Avro e Protobuf) e criptografia de campos. Ao contrário do pacote
+// publisher, não registra nenhuma function, então os consumidores podem
+// importá-lo sem efeitos colaterais.
+package message
@@ -0,0 +1,357 @@
+package message
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Atributos das mensagens com campos cifrados: a chave do KMS que cifrou a
+// chave de dados, a chave de dados cifrada e os campos cifrados
+const (
+	AttrEncryptionKey   = "encryption_key"
+	AttrEncryptedDEK    = "encryption_dek"
+	AttrEncryptedFields = "encrypted_fields"
+)
+
+// KMSLocal é o KMS do keyring local em arquivo
+const KMSLocal = "local"
+
+// dataKeySize é o tamanho da chave de dados (AES-256)
+const dataKeySize = 32
+
+// KMS cifra e decifra as chaves de dados com uma chave mestra que não sai
+// dele. O formato é o do Cloud KMS (Encrypt e Decrypt pelo nome da chave);
+// um adaptador sobre o KeyManagementClient basta para usá-lo.
+type KMS interface {
+	Encrypt(ctx context.Context, keyName string, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, keyName string, ciphertext []byte) ([]byte, error)
+}
+
+// OpenKMS abre o KMS pelo tipo ("" devolve nil, sem criptografia). O local
+// lê as chaves do arquivo keyring.
+func OpenKMS(kind, keyring string) (KMS, error) {
+	switch kind {
+	case "":
+		return nil, nil
+	case KMSLocal:
+		return LoadLocalKeyring(keyring)
+	default:
+		return nil, fmt.Errorf("KMS inválido: %q", kind)
+	}
+}
+
+// LocalKeyring é um KMS em memória, com as chaves mestras lidas de um
+// arquivo. Serve para testes e desenvolvimento local.
+type LocalKeyring struct {
+	keys map[string]cipher.AEAD
+}
+
+// Os keyrings são lidos uma vez por instância
+var (
+	keyringsMu sync.Mutex
+	keyrings   = map[string]*LocalKeyring{}
+)
+
+// LoadLocalKeyring lê (ou devolve do cache) o keyring do arquivo, um objeto
+// JSON com o nome de cada chave e a chave AES em base64
+func LoadLocalKeyring(path string) (*LocalKeyring, error) {
+	keyringsMu.Lock()
+	defer keyringsMu.Unlock()
+	if k, ok := keyrings[path]; ok {
+		return k, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o keyring: %w", err)
+	}
+	var encoded map[string]string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, fmt.Errorf("keyring %s inválido: %w", path, err)
+	}
+	keys := make(map[string][]byte, len(encoded))
+	for name, v := range encoded {
+		if keys[name], err = base64.StdEncoding.DecodeString(v); err != nil {
+			return nil, fmt.Errorf("keyring %s inválido: chave %s: %w", path, name, err)
+		}
+	}
+	k, err := NewLocalKeyring(keys)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s inválido: %w", path, err)
+	}
+	keyrings[path] = k
+	return k, nil
+}
+
+// NewLocalKeyring cria o keyring com as chaves AES (16, 24 ou 32 bytes) pelo nome
+func NewLocalKeyring(keys map[string][]byte) (*LocalKeyring, error) {
+	k := &LocalKeyring{keys: make(map[string]cipher.AEAD, len(keys))}
+	for name, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("chave %s: %w", name, err)
+		}
+		k.keys[name] = aead
+	}
+	return k, nil
+}
+
+func (k *LocalKeyring) Encrypt(ctx context.Context, keyName string, plaintext []byte) ([]byte, error) {
+	aead, ok := k.keys[keyName]
+	if !ok {
+		return nil, fmt.Errorf("chave %q não está no keyring", keyName)
+	}
+	return seal(aead, plaintext, []byte(keyName))
+}
+
+func (k *LocalKeyring) Decrypt(ctx context.Context, keyName string, ciphertext []byte) ([]byte, error) {
+	aead, ok := k.keys[keyName]
+	if !ok {
+		return nil, fmt.Errorf("chave %q não está no keyring", keyName)
+	}
+	return open(aead, ciphertext, []byte(keyName))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal cifra com um nonce aleatório, que vai na frente do resultado
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, additional []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("texto cifrado curto demais")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
+
+// FieldEncryptor cifra os campos sensíveis do payload com envelope
+// encryption: chaves de dados AES-GCM cifradas pela chave KeyName do KMS
+type FieldEncryptor struct {
+	KMS     KMS
+	KeyName string
+}
+
+// DataKey é uma chave de dados, com a versão cifrada pelo KMS que vai nos
+// atributos das mensagens. O publisher usa uma por execução.
+type DataKey struct {
+	aead    cipher.AEAD
+	keyName string
+	wrapped string
+}
+
+// NewDataKey gera uma chave de dados e a cifra no KMS
+func (e *FieldEncryptor) NewDataKey(ctx context.Context) (*DataKey, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := e.KMS.Encrypt(ctx, e.KeyName, key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao cifrar a chave de dados com %s: %w", e.KeyName, err)
+	}
+	return &DataKey{aead: aead, keyName: e.KeyName, wrapped: base64.StdEncoding.EncodeToString(wrapped)}, nil
+}
+
+// EncryptFields troca cada campo do payload pelo seu valor JSON cifrado, em
+// base64. O caminho do campo entra como dado autenticado, para que o valor
+// não possa ser movido para outro campo. Devolve os campos cifrados, só os
+// presentes no payload.
+func (k *DataKey) EncryptFields(payload []byte, fields []string) ([]byte, []string, error) {
+	obj, err := decodeObject(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	var encrypted []string
+	for _, field := range fields {
+		v, ok := lookupPath(obj, field)
+		if !ok || v == nil {
+			continue
+		}
+		plaintext, err := json.Marshal(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		ciphertext, err := seal(k.aead, plaintext, []byte(field))
+		if err != nil {
+			return nil, nil, err
+		}
+		setPath(obj, field, base64.StdEncoding.EncodeToString(ciphertext))
+		encrypted = append(encrypted, field)
+	}
+	if len(encrypted) == 0 {
+		return payload, nil, nil
+	}
+	out, err := json.Marshal(obj)
+	return out, encrypted, err
+}
+
+// Annotate marca nos atributos os campos cifrados e a chave de dados
+func (k *DataKey) Annotate(attrs map[string]string, encrypted []string) {
+	if len(encrypted) == 0 {
+		return
+	}
+	attrs[AttrEncryptionKey] = k.keyName
+	attrs[AttrEncryptedDEK] = k.wrapped
+	attrs[AttrEncryptedFields] = strings.Join(encrypted, ",")
+}
+
+func decodeObject(payload []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("o payload não é um objeto JSON")
+	}
+	return obj, nil
+}
+
+// lookupPath devolve o valor do caminho separado por pontos
+func lookupPath(obj map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = obj
+	for _, key := range strings.Split(path, ".") {
+		o, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = o[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// setPath grava v em um caminho que já existe no objeto
+func setPath(obj map[string]interface{}, path string, v interface{}) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		obj = next
+	}
+	obj[keys[len(keys)-1]] = v
+}
+
+// maxDataKeys limita o cache de chaves de dados do FieldDecryptor
+const maxDataKeys = 1000
+
+// FieldDecryptor decifra, do lado do consumidor, os campos cifrados pelo
+// publisher. As chaves de dados decifradas ficam em cache, já que todas as
+// mensagens de uma execução usam a mesma.
+type FieldDecryptor struct {
+	kms  KMS
+	mu   sync.Mutex
+	keys map[string]cipher.AEAD
+}
+
+// NewFieldDecryptor cria o FieldDecryptor sobre o KMS
+func NewFieldDecryptor(kms KMS) *FieldDecryptor {
+	return &FieldDecryptor{kms: kms, keys: map[string]cipher.AEAD{}}
+}
+
+// Decrypt devolve o payload com os campos do atributo encrypted_fields
+// decifrados. Sem o atributo, o payload volta inalterado.
+func (d *FieldDecryptor) Decrypt(ctx context.Context, payload []byte, attrs map[string]string) ([]byte, error) {
+	if attrs[AttrEncryptedFields] == "" {
+		return payload, nil
+	}
+	aead, err := d.dataKey(ctx, attrs[AttrEncryptionKey], attrs[AttrEncryptedDEK])
+	if err != nil {
+		return nil, err
+	}
+	obj, err := decodeObject(payload)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range strings.Split(attrs[AttrEncryptedFields], ",") {
+		v, ok := lookupPath(obj, field)
+		if !ok {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("campo %s: valor cifrado não é texto", field)
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("campo %s: %w", field, err)
+		}
+		plaintext, err := open(aead, ciphertext, []byte(field))
+		if err != nil {
+			return nil, fmt.Errorf("campo %s: %w", field, err)
+		}
+		value, err := decodeValue(plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("campo %s: %w", field, err)
+		}
+		setPath(obj, field, value)
+	}
+	return json.Marshal(obj)
+}
+
+// dataKey decifra (ou devolve do cache) a chave de dados da mensagem
+func (d *FieldDecryptor) dataKey(ctx context.Context, keyName, wrapped string) (cipher.AEAD, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if aead, ok := d.keys[keyName+"/"+wrapped]; ok {
+		return aead, nil
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("%s inválido: %w", AttrEncryptedDEK, err)
+	}
+	key, err := d.kms.Decrypt(ctx, keyName, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao decifrar a chave de dados com %s: %w", keyName, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.keys) >= maxDataKeys {
+		d.keys = map[string]cipher.AEAD{}
+	}
+	d.keys[keyName+"/"+wrapped] = aead
+	return aead, nil
+}
+
+func decodeValue(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,116 @@
+package message
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestKeyring(t *testing.T, names ...string) *LocalKeyring {
+	t.Helper()
+	keys := map[string][]byte{}
+	for i, name := range names {
+		keys[name] = bytes.Repeat([]byte{byte(i + 1)}, dataKeySize)
+	}
+	k, err := NewLocalKeyring(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestEncryptDecryptFields(t *testing.T) {
+	ctx := context.Background()
+	keyring := newTestKeyring(t, "dev")
+	payload := []byte(`{"id": 7, "name": "Ana", "customer": {"document": "123", "limit": 1500.5}, "tags": ["a"]}`)
+
+	dk, err := (&FieldEncryptor{KMS: keyring, KeyName: "dev"}).NewDataKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, encrypted, err := dk.EncryptFields(payload, []string{"name", "customer.document", "customer.limit", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"name", "customer.document", "customer.limit"}; !reflect.DeepEqual(encrypted, want) {
+		t.Errorf("campos cifrados = %v, want %v", encrypted, want)
+	}
+	for _, plain := range []string{"Ana", `"123"`, "1500.5"} {
+		if strings.Contains(string(out), plain) {
+			t.Errorf("payload cifrado ainda contém %s: %s", plain, out)
+		}
+	}
+
+	attrs := map[string]string{}
+	dk.Annotate(attrs, encrypted)
+	if attrs[AttrEncryptionKey] != "dev" || attrs[AttrEncryptedDEK] == "" || attrs[AttrEncryptedFields] != "name,customer.document,customer.limit" {
+		t.Errorf("atributos = %v", attrs)
+	}
+
+	decrypted, err := NewFieldDecryptor(keyring).Decrypt(ctx, out, attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonEqual(t, decrypted, payload)
+}
+
+func TestEncryptFieldsNothingToEncrypt(t *testing.T) {
+	dk, err := (&FieldEncryptor{KMS: newTestKeyring(t, "dev"), KeyName: "dev"}).NewDataKey(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"id": 1, "name": null}`)
+	out, encrypted, err := dk.EncryptFields(payload, []string{"name", "document"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encrypted) != 0 || !bytes.Equal(out, payload) {
+		t.Errorf("EncryptFields = %s, %v; want o payload inalterado", out, encrypted)
+	}
+	attrs := map[string]string{}
+	dk.Annotate(attrs, encrypted)
+	if len(attrs) != 0 {
+		t.Errorf("atributos = %v, want nenhum", attrs)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	ctx := context.Background()
+	keyring := newTestKeyring(t, "dev", "other")
+	dk, err := (&FieldEncryptor{KMS: keyring, KeyName: "dev"}).NewDataKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, encrypted, err := dk.EncryptFields([]byte(`{"a": "x", "b": "y"}`), []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs := map[string]string{}
+	dk.Annotate(attrs, encrypted)
+
+	// O valor de a movido para b não abre: o caminho é dado autenticado
+	var obj map[string]string
+	if err := json.Unmarshal(out, &obj); err != nil {
+		t.Fatal(err)
+	}
+	obj["b"] = obj["a"]
+	moved, _ := json.Marshal(obj)
+	if _, err := NewFieldDecryptor(keyring).Decrypt(ctx, moved, attrs); err == nil {
+		t.Error("Decrypt com o valor movido = nil, want erro")
+	}
+
+	// Nem com outra chave mestra
+	attrs[AttrEncryptionKey] = "other"
+	if _, err := NewFieldDecryptor(keyring).Decrypt(ctx, out, attrs); err == nil {
+		t.Error("Decrypt com outra chave = nil, want erro")
+	}
+}
+
+func TestNewLocalKeyringInvalidKey(t *testing.T) {
+	if _, err := NewLocalKeyring(map[string][]byte{"dev": []byte("curta")}); err == nil {
+		t.Error("NewLocalKeyring com chave de 5 bytes = nil, want erro")
+	}
+}
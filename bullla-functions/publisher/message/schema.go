@@ -0,0 +1,266 @@
+package message
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Tipos de schema dos tópicos
+const (
+	SchemaAvro     = "avro"
+	SchemaProtobuf = "protobuf"
+)
+
+// Codificações das mensagens. O Pub/Sub informa a usada
+// no atributo googclient_schemaencoding, em maiúsculas.
+const (
+	EncodingBinary = "binary"
+	EncodingJSON   = "json"
+)
+
+// Schema codifica os payloads JSON no formato do schema do tópico
+// (Avro ou Protobuf) e decodifica as mensagens de volta para JSON
+type Schema struct {
+	Type string
+
+	// Avro: o codec padrão (uniões no formato {"tipo": valor}) e o que lê
+	// e escreve JSON comum, além dos defaults dos campos do registro
+	avro         *goavro.Codec
+	avroStandard *goavro.Codec
+	avroDefaults map[string]json.RawMessage
+
+	// Protobuf: a única mensagem de primeiro nível do arquivo
+	message protoreflect.MessageDescriptor
+}
+
+// Os schemas são compilados uma vez por instância
+var (
+	messageSchemasMu sync.Mutex
+	messageSchemas   = map[string]*Schema{}
+)
+
+// LoadSchema compila (ou devolve do cache) o schema do arquivo. Sem
+// schemaType, o tipo vem da extensão (.avsc ou .proto).
+func LoadSchema(path, schemaType string) (*Schema, error) {
+	if schemaType == "" {
+		schemaType = SchemaTypeFromPath(path)
+	}
+	messageSchemasMu.Lock()
+	defer messageSchemasMu.Unlock()
+	if s, ok := messageSchemas[schemaType+":"+path]; ok {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o schema: %w", err)
+	}
+	s, err := ParseSchema(schemaType, data)
+	if err != nil {
+		return nil, fmt.Errorf("schema %s inválido: %w", path, err)
+	}
+	messageSchemas[schemaType+":"+path] = s
+	return s, nil
+}
+
+// SchemaTypeFromPath deduz o tipo do schema pela extensão do arquivo ("" se
+// não reconhecida)
+func SchemaTypeFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".avsc", ".avro":
+		return SchemaAvro
+	case ".proto":
+		return SchemaProtobuf
+	}
+	return ""
+}
+
+// ParseSchema compila a definição de um schema do Pub/Sub
+func ParseSchema(schemaType string, definition []byte) (*Schema, error) {
+	switch schemaType {
+	case SchemaAvro:
+		return parseAvroSchema(definition)
+	case SchemaProtobuf:
+		return parseProtoSchema(definition)
+	default:
+		return nil, fmt.Errorf("tipo de schema inválido: %q (deve ser %s ou %s)", schemaType, SchemaAvro, SchemaProtobuf)
+	}
+}
+
+func parseAvroSchema(definition []byte) (*Schema, error) {
+	codec, err := goavro.NewCodec(string(definition))
+	if err != nil {
+		return nil, err
+	}
+	standard, err := goavro.NewCodecForStandardJSONFull(string(definition))
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{Type: SchemaAvro, avro: codec, avroStandard: standard}
+
+	// O goavro não aplica os defaults ao ler JSON, então os campos com
+	// default que faltarem no payload são preenchidos antes
+	var record struct {
+		Type   interface{} `json:"type"`
+		Fields []struct {
+			Name    string          `json:"name"`
+			Default json.RawMessage `json:"default"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(definition, &record); err == nil && record.Type == "record" {
+		for _, f := range record.Fields {
+			if f.Default != nil {
+				if s.avroDefaults == nil {
+					s.avroDefaults = map[string]json.RawMessage{}
+				}
+				s.avroDefaults[f.Name] = f.Default
+			}
+		}
+	}
+	return s, nil
+}
+
+func parseProtoSchema(definition []byte) (*Schema, error) {
+	const name = "schema.proto"
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{name: string(definition)}),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+	// Como no Pub/Sub, o arquivo define uma única mensagem de primeiro nível
+	messages := files[0].Messages()
+	if messages.Len() != 1 {
+		return nil, fmt.Errorf("o schema Protobuf deve ter uma única mensagem de primeiro nível, tem %d", messages.Len())
+	}
+	return &Schema{Type: SchemaProtobuf, message: messages.Get(0)}, nil
+}
+
+// ValidateEncoding confere o nome da codificação (binary ou json)
+func ValidateEncoding(encoding string) error {
+	switch encoding {
+	case EncodingBinary, EncodingJSON:
+		return nil
+	default:
+		return fmt.Errorf("deve ser %s ou %s", EncodingBinary, EncodingJSON)
+	}
+}
+
+// Encode converte o payload JSON para o schema, na codificação informada
+func (s *Schema) Encode(payload []byte, encoding string) ([]byte, error) {
+	encoding = strings.ToLower(encoding)
+	if err := ValidateEncoding(encoding); err != nil {
+		return nil, fmt.Errorf("codificação %q: %w", encoding, err)
+	}
+	if s.Type == SchemaProtobuf {
+		msg := dynamicpb.NewMessage(s.message)
+		if err := protojson.Unmarshal(payload, msg); err != nil {
+			return nil, err
+		}
+		if encoding == EncodingJSON {
+			return protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+		}
+		return proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	}
+
+	payload, err := s.withAvroDefaults(payload)
+	if err != nil {
+		return nil, err
+	}
+	native, _, err := s.avroStandard.NativeFromTextual(payload)
+	if err != nil {
+		return nil, err
+	}
+	if encoding == EncodingJSON {
+		return s.avro.TextualFromNative(nil, native)
+	}
+	return s.avro.BinaryFromNative(nil, native)
+}
+
+// withAvroDefaults preenche os campos com default ausentes do payload
+func (s *Schema) withAvroDefaults(payload []byte) ([]byte, error) {
+	if len(s.avroDefaults) == 0 {
+		return payload, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+	missing := false
+	for name, def := range s.avroDefaults {
+		if _, ok := fields[name]; !ok {
+			fields[name] = def
+			missing = true
+		}
+	}
+	if !missing {
+		return payload, nil
+	}
+	return json.Marshal(fields)
+}
+
+// Decode converte uma mensagem codificada com o schema de volta para JSON.
+// encoding aceita o valor do atributo googclient_schemaencoding.
+func (s *Schema) Decode(data []byte, encoding string) ([]byte, error) {
+	encoding = strings.ToLower(encoding)
+	if err := ValidateEncoding(encoding); err != nil {
+		return nil, fmt.Errorf("codificação %q: %w", encoding, err)
+	}
+	if s.Type == SchemaProtobuf {
+		msg := dynamicpb.NewMessage(s.message)
+		var err error
+		if encoding == EncodingJSON {
+			err = protojson.Unmarshal(data, msg)
+		} else {
+			err = proto.Unmarshal(data, msg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	}
+
+	var native interface{}
+	var rest []byte
+	var err error
+	if encoding == EncodingJSON {
+		native, rest, err = s.avro.NativeFromTextual(data)
+	} else {
+		native, rest, err = s.avro.NativeFromBinary(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return nil, fmt.Errorf("%d bytes sobrando depois da mensagem Avro", len(rest))
+	}
+	return s.avroStandard.TextualFromNative(nil, native)
+}
+
+// ContentType é o content type das mensagens codificadas
+func (s *Schema) ContentType(encoding string) string {
+	switch {
+	case encoding == EncodingJSON:
+		return "application/json"
+	case s.Type == SchemaProtobuf:
+		return "application/x-protobuf"
+	default:
+		return "application/avro"
+	}
+}
@@ -0,0 +1,141 @@
+package message
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testAvroSchema = `{
+  "type": "record",
+  "name": "Pedido",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "amount", "type": "double"},
+    {"name": "items", "type": "long"},
+    {"name": "paid", "type": "boolean"},
+    {"name": "channel", "type": "string", "default": "web"}
+  ]
+}`
+
+const testProtoSchema = `syntax = "proto3";
+
+message Pedido {
+  string id = 1;
+  double amount = 2;
+  int32 items = 3;
+  bool paid = 4;
+  repeated string tags = 5;
+}
+`
+
+// jsonEqual compara dois documentos JSON pelo conteúdo
+func jsonEqual(t *testing.T, got, want []byte) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("JSON inválido %s: %v", got, err)
+	}
+	if err := json.Unmarshal(want, &w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestSchemaRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		schemaType string
+		definition string
+		payload    string
+		want       string
+	}{
+		{
+			name:       "avro",
+			schemaType: SchemaAvro,
+			definition: testAvroSchema,
+			payload:    `{"id": "p1", "amount": 10.5, "items": 3, "paid": true}`,
+			want:       `{"id": "p1", "amount": 10.5, "items": 3, "paid": true, "channel": "web"}`,
+		},
+		{
+			name:       "protobuf",
+			schemaType: SchemaProtobuf,
+			definition: testProtoSchema,
+			payload:    `{"id": "p1", "amount": 10.5, "items": 3, "paid": true, "tags": ["a", "b"]}`,
+			want:       `{"id": "p1", "amount": 10.5, "items": 3, "paid": true, "tags": ["a", "b"]}`,
+		},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchema(tt.schemaType, []byte(tt.definition))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for _, encoding := range []string{EncodingBinary, EncodingJSON} {
+			t.Run(tt.name+"/"+encoding, func(t *testing.T) {
+				data, err := s.Encode([]byte(tt.payload), encoding)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// O atributo googclient_schemaencoding vem em maiúsculas
+				decoded, err := s.Decode(data, map[string]string{EncodingBinary: "BINARY", EncodingJSON: "JSON"}[encoding])
+				if err != nil {
+					t.Fatal(err)
+				}
+				jsonEqual(t, decoded, []byte(tt.want))
+			})
+		}
+	}
+}
+
+func TestSchemaEncodeInvalid(t *testing.T) {
+	tests := []struct {
+		schemaType string
+		definition string
+		payload    string
+	}{
+		{SchemaAvro, testAvroSchema, `{"id": "p1", "amount": "dez", "items": 3, "paid": true}`},
+		{SchemaAvro, testAvroSchema, `{"id": "p1"}`},
+		{SchemaProtobuf, testProtoSchema, `{"id": "p1", "unknown": 1}`},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchema(tt.schemaType, []byte(tt.definition))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Encode([]byte(tt.payload), EncodingBinary); err == nil {
+			t.Errorf("%s: Encode(%s) = nil, want erro", tt.schemaType, tt.payload)
+		}
+	}
+}
+
+func TestParseSchemaInvalid(t *testing.T) {
+	tests := []struct {
+		schemaType string
+		definition string
+	}{
+		{SchemaAvro, `{"type": "record"}`},
+		{SchemaProtobuf, `syntax = "proto3"; message A { string a = 1; } message B { string b = 1; }`},
+		{SchemaProtobuf, `syntax = "proto3"; message A { string a = ; }`},
+	}
+	for _, tt := range tests {
+		if _, err := ParseSchema(tt.schemaType, []byte(tt.definition)); err == nil {
+			t.Errorf("ParseSchema(%s, %s) = nil, want erro", tt.schemaType, tt.definition)
+		}
+	}
+}
+
+func TestSchemaTypeFromPath(t *testing.T) {
+	tests := map[string]string{
+		"schemas/pedido.avsc": SchemaAvro,
+		"pedido.AVRO":         SchemaAvro,
+		"pedido.proto":        SchemaProtobuf,
+		"pedido.json":         "",
+	}
+	for path, want := range tests {
+		if got := SchemaTypeFromPath(path); got != want {
+			t.Errorf("SchemaTypeFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
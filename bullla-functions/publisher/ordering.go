@@ -0,0 +1,83 @@
+package publisher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxOrderingKey é o tamanho máximo de uma ordering key no Pub/Sub
+const maxOrderingKey = 1024
+
+// KeyTemplate monta a ordering key de cada mensagem a partir dos campos do
+// payload. Pode ser só o caminho de um campo ("customer.id") ou um texto com
+// campos entre chaves ("{region}:{customer.id}").
+type KeyTemplate struct {
+	parts []keyPart
+}
+
+type keyPart struct {
+	literal string
+	field   string
+}
+
+// parseKeyTemplate interpreta o ORDERING_KEY
+func parseKeyTemplate(expr string) (*KeyTemplate, error) {
+	t := &KeyTemplate{}
+	if !strings.ContainsAny(expr, "{}") {
+		t.parts = []keyPart{{field: strings.TrimSpace(expr)}}
+		return t, nil
+	}
+
+	rest := expr
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			if strings.ContainsRune(rest, '}') {
+				return nil, fmt.Errorf("ORDERING_KEY inválido: '}' sem '{' em %q", expr)
+			}
+			t.parts = append(t.parts, keyPart{literal: rest})
+			break
+		}
+		if strings.ContainsRune(rest[:open], '}') {
+			return nil, fmt.Errorf("ORDERING_KEY inválido: '}' sem '{' em %q", expr)
+		}
+		if open > 0 {
+			t.parts = append(t.parts, keyPart{literal: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("ORDERING_KEY inválido: '{' sem '}' em %q", expr)
+		}
+		field := strings.TrimSpace(rest[open+1 : open+end])
+		if field == "" {
+			return nil, fmt.Errorf("ORDERING_KEY inválido: campo vazio em %q", expr)
+		}
+		t.parts = append(t.parts, keyPart{field: field})
+		rest = rest[open+end+1:]
+	}
+	return t, nil
+}
+
+// Render monta a ordering key do registro. Falta de algum dos campos, ou uma
+// key vazia, é erro: publicada sem key, a mensagem sairia fora de ordem.
+func (t *KeyTemplate) Render(rec Record) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.field == "" {
+			b.WriteString(part.literal)
+			continue
+		}
+		v, ok := recordField(rec, part.field)
+		if !ok {
+			return "", fmt.Errorf("ordering key: campo %s ausente", part.field)
+		}
+		b.WriteString(v)
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("ordering key vazia")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,98 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestKeyTemplateRender(t *testing.T) {
+	rec := Record{"region": "sul", "customer": map[string]interface{}{"id": 42.0}, "vazio": ""}
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr bool
+	}{
+		{"region", "sul", false},
+		{"customer.id", "42", false},
+		{"{region}:{customer.id}", "sul:42", false},
+		{"cliente-{customer.id}", "cliente-42", false},
+		{"{region}:{customer.nome}", "", true},
+		{"vazio", "", true},
+	}
+	for _, tt := range tests {
+		tmpl, err := parseKeyTemplate(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		got, err := tmpl.Render(rec)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%s: Render = %q, %v; want %q, erro %v", tt.expr, got, err, tt.want, tt.wantErr)
+		}
+	}
+	for _, expr := range []string{"{region", "region}", "{}:{region}"} {
+		if _, err := parseKeyTemplate(expr); err == nil {
+			t.Errorf("%s: parseKeyTemplate aceitou o template", expr)
+		}
+	}
+}
+
+func TestRunOrderingKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 1, "customer": "c1"}, {"id": 2}, {"id": 3, "customer": "c1"}, {"id": 4, "customer": "c2"}]`)
+	}))
+	defer srv.Close()
+
+	// O tópico "ausente" não existe: as publicações falham e pausam a key
+	client := newTestClient(t, "topico")
+	cfg := loadTestConfig(t, map[string]string{
+		"ENDPOINT_SERVER": srv.URL,
+		"TOPIC_ID":        "topico",
+		"ORDERING_KEY":    "customer",
+		"DRY_RUN_SAMPLES": "10",
+	})
+	run := func(topic string, dryRun bool) *Result {
+		t.Helper()
+		c := *cfg
+		c.TopicID, c.DryRun = topic, dryRun
+		p, err := NewPipeline(&c, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// O registro sem o campo da key é rejeitado, não publicado sem ordem
+	result := run("topico", true)
+	var keys []string
+	for _, m := range result.Messages {
+		keys = append(keys, m.ID+"="+m.OrderingKey)
+	}
+	if want := []string{"1=c1", "3=c1", "4=c2"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("ordering keys = %v, want %v", keys, want)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0].ID != "2" {
+		t.Errorf("rejected = %+v, want só o registro 2", result.Rejected)
+	}
+
+	result = run("topico", false)
+	if result.Published != 3 || result.Errors != 0 || len(result.PausedKeys) != 0 {
+		t.Errorf("published=%d errors=%d paused=%v, want 3, 0, nenhuma", result.Published, result.Errors, result.PausedKeys)
+	}
+
+	// Com falha, as keys afetadas são retomadas e listadas
+	result = run("ausente", false)
+	if result.Published != 0 || result.Errors != 3 {
+		t.Errorf("published=%d errors=%d, want 0, 3", result.Published, result.Errors)
+	}
+	if want := []string{"c1", "c2"}; !reflect.DeepEqual(result.PausedKeys, want) {
+		t.Errorf("paused keys = %v, want %v", result.PausedKeys, want)
+	}
+}
@@ -0,0 +1,882 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/message"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Result resume uma execução do publisher
+type Result struct {
+	RunID string // vai no atributo run_id de cada mensagem
+	FetchStats
+	Selected  uint64 // registros que passaram pelos filtros, pela validação e pela seleção
+	Filtered  uint64 // inclui os que ficaram fora da amostra
+	Duplicate uint64 // já publicados dentro da janela do DEDUP_TTL
+	Skipped   uint64 // descartados pelo offset ou, no modo tail, pelo limite
+	Published uint64
+	Errors    uint64
+	DryRun    bool
+
+	// LimitReached indica que a leitura parou no LIMIT, antes do fim da origem
+	LimitReached bool
+
+	// ClaimChecks são as mensagens publicadas por referência a um blob e
+	// BlobsCleaned os blobs vencidos removidos no final
+	ClaimChecks  uint64
+	BlobsCleaned int
+
+	// Rejected traz os primeiros maxReportMessages registros rejeitados;
+	// RejectedOmitted conta os que ficaram de fora
+	Rejected        []Rejection
+	RejectedOmitted int
+
+	// PausedKeys são as ordering keys que tiveram uma publicação com falha.
+	// O Pub/Sub pausa a key nesse caso; o pipeline chama ResumePublish, mas
+	// as mensagens da key que falharam não foram entregues.
+	PausedKeys []string
+
+	// Sources detalha cada origem de um fan-in (SOURCES), pela tag
+	Sources map[string]*SourceResult
+
+	// Topics detalha cada tópico que recebeu registros, pelo ID
+	Topics map[string]*TopicResult
+
+	// Messages traz o resultado dos registros publicados, na ordem de
+	// publicação: as falhas e os primeiros ReportMessages sucessos, até
+	// maxReportMessages de cada. MessagesOmitted conta os que ficaram de fora.
+	// Durations é o tempo de cada etapa.
+	Messages        []MessageResult
+	MessagesOmitted int
+	Durations       Durations
+}
+
+// maxReportMessages limita os resultados de mensagens e as rejeições
+// guardados para a resposta, para que uma origem grande não acumule tudo em
+// memória
+const maxReportMessages = 1000
+
+// RejectedCount é o total de registros rejeitados, listados ou não
+func (r *Result) RejectedCount() int {
+	return len(r.Rejected) + r.RejectedOmitted
+}
+
+// errLimitReached interrompe a leitura da origem quando o limite é atingido
+var errLimitReached = errors.New("limite de registros atingido")
+
+// NewTopic devolve o tópico com o batching e o flow control informados. Com
+// FlowControlBlock o Publish bloqueia ao atingir os limites, o que também
+// pausa a leitura da origem.
+func NewTopic(c *pubsub.Client, topicID string, settings PublishConfig) *pubsub.Topic {
+	t := c.Topic(topicID)
+	settings.Apply(t)
+	logrus.Debugf("Topic %s publish settings: %s", topicID, settings)
+	return t
+}
+
+// Os tópicos mantêm goroutines e buffers de batching, então cada um é criado
+// uma vez por instância e compartilhado entre invocações. A chave inclui as
+// settings e a ordenação, que não mudam depois do primeiro Publish.
+type topicKey struct {
+	client   *pubsub.Client
+	id       string
+	settings PublishConfig
+	ordering bool
+}
+
+var (
+	sharedTopicsMu sync.Mutex
+	sharedTopics   = map[topicKey]*pubsub.Topic{}
+)
+
+func sharedTopic(c *pubsub.Client, topicID string, settings PublishConfig, ordering bool) *pubsub.Topic {
+	sharedTopicsMu.Lock()
+	defer sharedTopicsMu.Unlock()
+	key := topicKey{client: c, id: topicID, settings: settings, ordering: ordering}
+	if t, ok := sharedTopics[key]; ok {
+		return t
+	}
+	t := NewTopic(c, topicID, settings)
+	t.EnableMessageOrdering = ordering
+	sharedTopics[key] = t
+	return t
+}
+
+// StopTopics envia as mensagens pendentes e libera os tópicos da instância.
+// Run já espera o resultado de todas as mensagens, então basta chamá-lo no
+// desligamento; um tópico usado depois disso é criado de novo.
+func StopTopics() {
+	sharedTopicsMu.Lock()
+	defer sharedTopicsMu.Unlock()
+	for key, t := range sharedTopics {
+		t.Stop()
+		delete(sharedTopics, key)
+	}
+}
+
+// Pipeline lê os registros da origem e publica cada um no tópico
+type Pipeline struct {
+	Topic  *pubsub.Topic
+	Source Source
+
+	// Routes (opcional) escolhe outro tópico conforme o conteúdo do registro.
+	// Os tópicos das rotas são criados no primeiro uso e reaproveitados até o
+	// StopTopics.
+	Routes  []*Route
+	Publish PublishConfig
+	client  *pubsub.Client
+
+	// Leitura incremental (opcional): checkpoint salvo em State sob StateKey,
+	// com a high-water mark calculada a partir de SinceField
+	State      StateStore
+	StateKey   string
+	SinceField string
+
+	// Seleção dos registros: entre os que casam com Filters e com a expressão
+	// Filter, Selection aplica amostragem, offset e limite. Em DryRun nada é
+	// publicado nem o checkpoint avança; o resultado traz o payload dos
+	// primeiros DryRunSamples registros.
+	Filters       map[string]interface{}
+	Filter        *Filter
+	Selection     Selection
+	DryRun        bool
+	DryRunSamples int
+
+	// ReportMessages é quantos resultados de sucesso vão em Result.Messages
+	ReportMessages int
+
+	// Atributos de cada mensagem: os padrão (source_url, run_id...) e os
+	// extraídos do payload
+	Attributes    []AttributeSpec
+	SchemaVersion string
+
+	// MessageSchema (opcional) codifica os payloads em Avro ou Protobuf, em
+	// MessageEncoding, para os tópicos com schema. Os registros que não
+	// seguem o schema são rejeitados.
+	MessageSchema   *message.Schema
+	MessageEncoding string
+
+	// Encryption (opcional) cifra os campos sensíveis antes de publicar.
+	// Filtros, validação e ID_FIELD usam os valores originais; atributos,
+	// ordering key e rotas não podem usar campos cifrados, o que
+	// validateEncryption confere ao carregar a configuração.
+	Encryption    *message.FieldEncryptor
+	EncryptFields []string
+
+	// Compression (opcional) comprime os payloads com pelo menos
+	// CompressionThreshold bytes e marca o atributo content-encoding
+	Compression          string
+	CompressionThreshold int
+
+	// Claim-check (opcional): payloads maiores que ClaimCheckThreshold vão
+	// para Blobs e a mensagem leva só a referência. Com BlobTTL, cada
+	// execução remove os blobs mais antigos que isso.
+	Blobs               message.BlobStore
+	ClaimCheckThreshold int
+	BlobTTL             time.Duration
+
+	sourceURLs map[string]string // pela tag da origem ("" com uma origem só)
+
+	// OrderingKey (opcional) monta a ordering key de cada mensagem; com ela o
+	// tópico publica em ordem as mensagens da mesma key
+	OrderingKey *KeyTemplate
+
+	// Deduplicação (opcional): IDs (IDField) já publicados sob StateKey
+	// dentro da janela do store são suprimidos
+	Dedup DedupStore
+
+	// Mapping monta o payload a partir do registro da origem (nil = inalterado)
+	Mapping *Mapping
+
+	// Validação (opcional) do payload contra um JSON Schema. Os inválidos
+	// vão para RejectTopic, se configurado, ou são descartados.
+	Validator   *Validator
+	RejectTopic *pubsub.Topic
+	IDField     string
+}
+
+// NewPipeline monta o pipeline a partir da configuração
+func NewPipeline(cfg *Config, c *pubsub.Client) (*Pipeline, error) {
+	src, err := NewSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	state, err := NewStateStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var validator *Validator
+	if cfg.SchemaFile != "" {
+		if validator, err = loadValidator(cfg.SchemaFile); err != nil {
+			return nil, err
+		}
+	}
+	dedup, err := NewDedupStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := loadMapping(cfg.Mapping, cfg.MappingFile)
+	if err != nil {
+		return nil, err
+	}
+	var filter *Filter
+	if cfg.Filter != "" {
+		if filter, err = compileFilter(cfg.Filter); err != nil {
+			return nil, err
+		}
+	}
+	var messageSchema *message.Schema
+	if cfg.MessageSchemaFile != "" {
+		if messageSchema, err = message.LoadSchema(cfg.MessageSchemaFile, cfg.MessageSchemaType); err != nil {
+			return nil, fmt.Errorf("MESSAGE_SCHEMA_FILE: %w", err)
+		}
+	}
+	var encryption *message.FieldEncryptor
+	if len(cfg.EncryptFields) > 0 {
+		kms, err := NewKMS(cfg)
+		if err != nil {
+			return nil, err
+		}
+		encryption = &message.FieldEncryptor{KMS: kms, KeyName: cfg.EncryptionKey}
+	}
+	var orderingKey *KeyTemplate
+	if cfg.OrderingKey != "" {
+		if orderingKey, err = parseKeyTemplate(cfg.OrderingKey); err != nil {
+			return nil, err
+		}
+	}
+	blobs, err := NewBlobStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var blobTTL time.Duration
+	if cfg.ClaimCheckCleanup == claimCheckCleanupRun {
+		blobTTL = cfg.ClaimCheckTTL
+	}
+	routes, err := loadRoutes(cfg.Routes, cfg.RoutesFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		if err := route.Settings.merge(cfg.Publish).Validate(); err != nil {
+			return nil, fmt.Errorf("settings do tópico %s: %w", route.Topic, err)
+		}
+	}
+	var rejectTopic *pubsub.Topic
+	if cfg.RejectTopicID != "" {
+		rejectTopic = sharedTopic(c, cfg.RejectTopicID, cfg.Publish, false)
+	}
+
+	urls := map[string]string{"": sourceURL(cfg)}
+	if m, ok := src.(*multiSource); ok {
+		urls = m.urls
+	}
+
+	topic := sharedTopic(c, cfg.TopicID, routeSettings(routes, cfg.TopicID).merge(cfg.Publish), orderingKey != nil)
+
+	return &Pipeline{
+		Topic:                topic,
+		Routes:               routes,
+		Publish:              cfg.Publish,
+		client:               c,
+		OrderingKey:          orderingKey,
+		Attributes:           cfg.Attributes,
+		SchemaVersion:        cfg.SchemaVersion,
+		MessageSchema:        messageSchema,
+		MessageEncoding:      cfg.MessageEncoding,
+		Encryption:           encryption,
+		EncryptFields:        cfg.EncryptFields,
+		Compression:          cfg.Compression,
+		CompressionThreshold: cfg.CompressionThreshold,
+		Blobs:                blobs,
+		ClaimCheckThreshold:  cfg.ClaimCheckThreshold,
+		BlobTTL:              blobTTL,
+		sourceURLs:           urls,
+		Dedup:                dedup,
+		Mapping:              mapping,
+		Validator:            validator,
+		RejectTopic:          rejectTopic,
+		IDField:              cfg.IDField,
+		Source:               src,
+		State:                state,
+		StateKey:             cfg.CheckpointKey,
+		SinceField:           cfg.SinceField,
+		Selection:            cfg.Selection,
+		Filters:              cfg.Filters,
+		Filter:               filter,
+		DryRun:               cfg.DryRun,
+		DryRunSamples:        cfg.DryRunSamples,
+		ReportMessages:       cfg.ReportMessages,
+	}, nil
+}
+
+// Run lê todos os registros da origem e publica cada um conforme é lido.
+// Erros de publicação são contados no resultado; o erro devolvido é o da
+// leitura da origem ou do checkpoint. O checkpoint só avança quando todos
+// os registros foram publicados, a origem foi lida até o fim e nenhuma
+// seleção (limite, offset ou amostragem) deixou registros de fora.
+func (p *Pipeline) Run(ctx context.Context) (*Result, error) {
+	start := time.Now()
+	r := &run{
+		p:      p,
+		ctx:    ctx,
+		result: &Result{RunID: uuid.NewString(), DryRun: p.DryRun, Topics: map[string]*TopicResult{}},
+		hw:     &highWater{field: p.SinceField},
+		sel:    newSelector(p.Selection),
+		sent:   map[string]bool{},
+	}
+	if p.State != nil {
+		loadStart := time.Now()
+		cp, err := p.State.Load(ctx, p.StateKey)
+		r.result.Durations.Checkpoint += time.Since(loadStart)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler o checkpoint: %w", err)
+		}
+		if cp != nil {
+			logrus.Debugf("Resuming from checkpoint %s: %+v", p.StateKey, *cp)
+			r.hw.value = cp.HighWater
+			if src, ok := p.Source.(resumable); ok {
+				src.Resume(cp)
+			}
+		}
+	}
+
+	if p.Encryption != nil {
+		// Uma chave de dados para todas as mensagens da execução: uma só
+		// chamada ao KMS
+		dk, err := p.Encryption.NewDataKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r.dataKey = dk
+	}
+
+	var stats FetchStats
+	var err error
+	fetchStart := time.Now()
+	if src, ok := p.Source.(fanIn); ok {
+		r.result.Sources = make(map[string]*SourceResult, len(src.Tags()))
+		for _, tag := range src.Tags() {
+			r.result.Sources[tag] = &SourceResult{}
+		}
+		stats, err = src.FetchTagged(ctx, r.timedProcess, func(tag string, st FetchStats, err error) {
+			sr := r.result.Sources[tag]
+			sr.FetchStats = st
+			if err != nil {
+				sr.Error = err.Error()
+			}
+		})
+	} else {
+		stats, err = p.Source.Fetch(ctx, func(rec Record) error { return r.timedProcess("", rec) })
+	}
+	if errors.Is(err, errLimitReached) {
+		logrus.Infof("Limit of %d records reached, stopping", p.Selection.Limit)
+		r.result.LimitReached = true
+		err = nil
+	}
+	if err == nil {
+		// No modo tail os registros retidos só saem no fim da leitura
+		flushStart := time.Now()
+		r.release(r.sel.flush())
+		r.processTime += time.Since(flushStart)
+	} else {
+		// Os registros retidos no modo tail não saem mais
+		for _, o := range r.sel.queue {
+			r.dropBlob(o.blob)
+		}
+	}
+	fetchTime := time.Since(fetchStart)
+	waitStart := time.Now()
+	r.wg.Wait()
+
+	result := r.result
+	result.FetchStats = stats
+	result.Durations.Fetch = fetchTime - r.processTime
+	result.Durations.Process = r.processTime - r.publishTime
+	result.Durations.Publish = r.publishTime + time.Since(waitStart)
+	sort.Slice(result.Messages, func(i, j int) bool { return result.Messages[i].Index < result.Messages[j].Index })
+	defer func() { result.Durations.Total = time.Since(start) }()
+
+	if p.Blobs != nil && p.BlobTTL > 0 && !p.DryRun {
+		// Uma falha na limpeza não afeta a execução; os blobs ficam para a próxima
+		cleanStart := time.Now()
+		n, cerr := p.Blobs.Cleanup(ctx, time.Now().Add(-p.BlobTTL))
+		result.Durations.Checkpoint += time.Since(cleanStart)
+		result.BlobsCleaned = n
+		if cerr != nil {
+			logrus.Warnf("Failed to clean up expired blobs: %v", cerr)
+		}
+	}
+	for key := range r.paused {
+		result.PausedKeys = append(result.PausedKeys, key)
+	}
+	sort.Strings(result.PausedKeys)
+
+	// Os publicados são registrados mesmo que a leitura tenha falhado no meio
+	if p.Dedup != nil && len(r.published) > 0 {
+		markStart := time.Now()
+		derr := p.Dedup.Mark(ctx, p.StateKey, r.published)
+		result.Durations.Checkpoint += time.Since(markStart)
+		if derr != nil {
+			if err != nil {
+				logrus.Errorf("Failed to save dedup IDs: %v", derr)
+				return result, err
+			}
+			return result, fmt.Errorf("erro ao salvar a deduplicação: %w", derr)
+		}
+	}
+	if err != nil {
+		return result, err
+	}
+
+	// Os validadores e a high-water mark cobririam os registros que a seleção
+	// deixou de fora: a próxima execução receberia 304 ou pularia esses registros
+	partial := result.LimitReached || result.Skipped > 0 || p.Selection.active()
+	if p.State != nil && partial && !p.DryRun {
+		logrus.Infof("Checkpoint %s not saved: selection left records out", p.StateKey)
+	}
+	if p.State != nil && result.Errors == 0 && !partial && !p.DryRun {
+		cp := &Checkpoint{HighWater: r.hw.value, UpdatedAt: time.Now().UTC()}
+		if src, ok := p.Source.(resumable); ok {
+			cp.ETag, cp.LastModified = src.Validators()
+		}
+		saveStart := time.Now()
+		err := p.State.Save(ctx, p.StateKey, cp)
+		result.Durations.Checkpoint += time.Since(saveStart)
+		if err != nil {
+			return result, fmt.Errorf("erro ao salvar o checkpoint: %w", err)
+		}
+		logrus.Debugf("Checkpoint %s saved: %+v", p.StateKey, *cp)
+	}
+	return result, nil
+}
+
+// route devolve o ID do tópico do registro: o da primeira rota que casa ou,
+// sem nenhuma, o do Topic
+func (p *Pipeline) route(msg Record) string {
+	for _, route := range p.Routes {
+		if route.matches(msg) {
+			return route.Topic
+		}
+	}
+	return p.Topic.ID()
+}
+
+// topic devolve o tópico pelo ID, com as configurações da rota
+func (p *Pipeline) topic(id string) *pubsub.Topic {
+	if id == p.Topic.ID() {
+		return p.Topic
+	}
+	return sharedTopic(p.client, id, routeSettings(p.Routes, id).merge(p.Publish), p.Topic.EnableMessageOrdering)
+}
+
+// run é o estado de uma execução do pipeline
+type run struct {
+	p      *Pipeline
+	ctx    context.Context
+	result *Result
+	hw     *highWater
+	sel    *selector
+	wg     sync.WaitGroup
+
+	// dataKey cifra os campos sensíveis desta execução (nil sem Encryption)
+	dataKey *message.DataKey
+
+	pausedMu sync.Mutex
+	paused   map[string]bool
+
+	// IDs desta execução: os já aceitos (para não repetir na mesma leitura)
+	// e os confirmados pelo Pub/Sub, que vão para o DedupStore no final
+	sent        map[string]bool
+	publishedMu sync.Mutex // protege published, hw, result.Messages e os contadores abaixo
+	published   []string
+	numMsgs     int
+	reportedOK  int
+	reportedErr int
+
+	// Tempo dentro de process e, dele, o bloqueado no Publish pelo flow control
+	processTime time.Duration
+	publishTime time.Duration
+}
+
+// timedProcess é o process medindo o tempo gasto
+func (r *run) timedProcess(tag string, rec Record) error {
+	start := time.Now()
+	defer func() { r.processTime += time.Since(start) }()
+	return r.process(tag, rec)
+}
+
+// process recebe cada registro da origem, na ordem de leitura. tag é a
+// origem do registro em um fan-in ("" com uma origem só).
+func (r *run) process(tag string, rec Record) error {
+	p, result := r.p, r.result
+	fetchedAt := time.Now()
+
+	// A high-water mark usa os campos da origem, já que volta para ela, e só
+	// avança com o registro publicado
+	var hw string
+	if r.hw.field != "" {
+		hw, _ = recordField(rec, r.hw.field)
+	}
+
+	// Filtros, validação, amostragem e ID_FIELD se referem ao payload já mapeado
+	msg, err := p.Mapping.Apply(rec)
+	if err != nil {
+		payload, _ := json.Marshal(rec)
+		r.reject(rec, payload, "mapping", []string{fmt.Sprintf("mapping: %v", err)})
+		return nil
+	}
+	if !matchFilters(msg, p.Filters) {
+		result.Filtered++
+		return nil
+	}
+	if ok, err := p.Filter.Match(msg); !ok {
+		if err != nil {
+			// Campo ausente ou de outro tipo: o registro não casa com o filtro
+			logrus.Debugf("Filter did not evaluate for record: %v", err)
+		}
+		result.Filtered++
+		return nil
+	}
+
+	messageJSON, err := json.Marshal(msg)
+	if err != nil {
+		logrus.Errorf("Erro ao converter mensagem para JSON: %v", err)
+		atomic.AddUint64(&result.Errors, 1)
+		return nil
+	}
+
+	id, hasID := recordField(msg, p.IDField)
+	sampleKey := messageJSON
+	if hasID {
+		sampleKey = []byte(id)
+	}
+	if !p.Selection.sampled(sampleKey) {
+		result.Filtered++
+		return nil
+	}
+
+	if p.Validator != nil {
+		if reasons := p.Validator.Validate(messageJSON); len(reasons) > 0 {
+			r.reject(msg, messageJSON, "schema_validation", reasons)
+			return nil
+		}
+	}
+
+	ref := id
+	if p.Dedup == nil || !hasID {
+		id = ""
+	} else {
+		dup := r.sent[id]
+		if !dup {
+			if dup, err = p.Dedup.Seen(r.ctx, p.StateKey, id); err != nil {
+				return fmt.Errorf("erro ao consultar a deduplicação: %w", err)
+			}
+		}
+		if dup {
+			logrus.Debugf("Record %q already published, skipping", id)
+			result.Duplicate++
+			return nil
+		}
+		r.sent[id] = true
+	}
+
+	key, err := p.OrderingKey.Render(msg)
+	if err != nil {
+		r.reject(msg, messageJSON, "ordering_key", []string{err.Error()})
+		return nil
+	}
+	if len(key) > maxOrderingKey {
+		r.reject(msg, messageJSON, "ordering_key", []string{fmt.Sprintf("ordering key com %d bytes (máximo %d)", len(key), maxOrderingKey)})
+		return nil
+	}
+
+	// Os campos sensíveis são cifrados no payload publicado; os rejeitados
+	// seguem com o JSON original, que reject cifra de novo
+	payload := messageJSON
+	var encrypted []string
+	if r.dataKey != nil {
+		if payload, encrypted, err = r.dataKey.EncryptFields(messageJSON, p.EncryptFields); err != nil {
+			logrus.Errorf("Erro ao cifrar a mensagem: %v", err)
+			atomic.AddUint64(&result.Errors, 1)
+			return nil
+		}
+	}
+
+	data := payload
+	if p.MessageSchema != nil {
+		if data, err = p.MessageSchema.Encode(payload, p.MessageEncoding); err != nil {
+			r.reject(msg, messageJSON, "message_schema", []string{fmt.Sprintf("schema %s: %v", p.MessageSchema.Type, err)})
+			return nil
+		}
+	}
+
+	attrs := r.attributes(msg, tag, fetchedAt)
+	if p.MessageSchema != nil {
+		attrs[attrContentType] = p.MessageSchema.ContentType(p.MessageEncoding)
+	}
+	if r.dataKey != nil {
+		r.dataKey.Annotate(attrs, encrypted)
+	}
+	if p.Compression != "" && len(data) >= p.CompressionThreshold {
+		compressed, ok, err := message.Compress(data, p.Compression)
+		if err != nil {
+			logrus.Errorf("Erro ao comprimir a mensagem: %v", err)
+			atomic.AddUint64(&result.Errors, 1)
+			return nil
+		}
+		if ok {
+			data = compressed
+			attrs[message.AttrContentEncoding] = p.Compression
+		}
+	}
+
+	// Claim-check: o payload vai para o BlobStore e a mensagem leva a
+	// referência. No dry run o blob não é gravado.
+	var blob string
+	if p.Blobs != nil && len(data) > p.ClaimCheckThreshold {
+		blob = result.RunID + "/" + uuid.NewString()
+		var uri string
+		if !p.DryRun {
+			if uri, err = p.Blobs.Put(r.ctx, blob, data); err != nil {
+				logrus.Errorf("Erro ao gravar o blob %s: %v", blob, err)
+				atomic.AddUint64(&result.Errors, 1)
+				return nil
+			}
+		}
+		if data, err = message.NewClaimCheckRef(blob, uri, data); err != nil {
+			r.dropBlob(blob)
+			logrus.Errorf("Erro ao converter a referência para JSON: %v", err)
+			atomic.AddUint64(&result.Errors, 1)
+			return nil
+		}
+		attrs[message.AttrClaimCheck] = message.ClaimCheckVersion
+	}
+
+	// Os limites do Pub/Sub valem para o payload já comprimido
+	if reasons := validateMessage(data, attrs, key); len(reasons) > 0 {
+		r.dropBlob(blob)
+		r.reject(msg, messageJSON, "pubsub_limits", reasons)
+		return nil
+	}
+
+	o := outgoing{id: id, ref: ref, tag: tag, topic: p.route(msg), key: key, attrs: attrs, data: data, raw: payload, blob: blob, hw: hw}
+	r.release(r.sel.add(o))
+	if r.sel.full() {
+		return errLimitReached
+	}
+	return nil
+}
+
+// release publica os registros liberados pela seleção e esquece os descartados
+func (r *run) release(ready, dropped []outgoing) {
+	for _, o := range dropped {
+		r.result.Skipped++
+		r.dropBlob(o.blob)
+		if o.id != "" {
+			delete(r.sent, o.id)
+		}
+	}
+	for _, o := range ready {
+		r.publish(o)
+	}
+}
+
+// publish envia o registro ao tópico; o resultado é contado em segundo plano
+func (r *run) publish(o outgoing) {
+	result := r.result
+	sr := result.Sources[o.tag]
+	if sr == nil {
+		// Uma origem só: os contadores por origem são descartados
+		sr = &SourceResult{}
+	}
+	tr := result.Topics[o.topic]
+	if tr == nil {
+		tr = &TopicResult{}
+		result.Topics[o.topic] = tr
+	}
+	result.Selected++
+	sr.Selected++
+	tr.Selected++
+	tr.Bytes += uint64(len(o.data))
+	i := r.numMsgs
+	r.numMsgs++
+	mr := MessageResult{Index: i, ID: o.ref, Source: o.tag, Topic: o.topic, Size: len(o.data)}
+	if len(o.raw) != len(o.data) {
+		mr.RawSize = len(o.raw)
+	}
+	mr.ClaimCheck = o.blob
+
+	if r.p.DryRun {
+		// Tudo já foi feito (mapeamento, validação, rota, atributos), menos o Publish
+		logrus.Debugf("Dry run, skipping message %d to %s: %s", i, o.topic, o.raw)
+		if i < r.p.DryRunSamples {
+			mr.Payload = json.RawMessage(o.raw)
+			mr.Attributes = o.attrs
+			mr.OrderingKey = o.key
+		}
+		r.addMessage(mr, "", o.hw)
+		return
+	}
+
+	msg := &pubsub.Message{Data: o.data, Attributes: o.attrs, OrderingKey: o.key}
+	topic := r.p.topic(o.topic)
+	start := time.Now()
+	res := topic.Publish(r.ctx, msg)
+	r.publishTime += time.Since(start)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		serverID, err := res.Get(r.ctx)
+		if err != nil {
+			mr.Error = err.Error()
+			r.addMessage(mr, "", "")
+			r.dropBlob(o.blob)
+			logrus.Errorf("Failed to publish message %d to %s: %v", i, o.topic, err)
+			atomic.AddUint64(&result.Errors, 1)
+			atomic.AddUint64(&sr.Errors, 1)
+			atomic.AddUint64(&tr.Errors, 1)
+			if o.key != "" {
+				r.resume(topic, o.key)
+			}
+			return
+		}
+		atomic.AddUint64(&result.Published, 1)
+		atomic.AddUint64(&sr.Published, 1)
+		atomic.AddUint64(&tr.Published, 1)
+		if o.blob != "" {
+			atomic.AddUint64(&result.ClaimChecks, 1)
+		}
+		logrus.Infof("Successfully published message %d to %s", i, o.topic)
+		mr.MessageID = serverID
+		r.addMessage(mr, o.id, o.hw)
+	}()
+}
+
+// dropBlob remove o blob de uma mensagem que não foi publicada
+func (r *run) dropBlob(key string) {
+	if key == "" || r.p.DryRun {
+		return
+	}
+	if err := r.p.Blobs.Delete(r.ctx, key); err != nil {
+		logrus.Warnf("Failed to delete blob %s: %v", key, err)
+	}
+}
+
+// addMessage registra o resultado do registro e, se publicado, o ID de
+// deduplicação para o DedupStore e o valor da high-water mark
+func (r *run) addMessage(mr MessageResult, dedupID, hw string) {
+	r.publishedMu.Lock()
+	defer r.publishedMu.Unlock()
+	r.hw.observe(hw)
+	if r.keepMessage(mr) {
+		r.result.Messages = append(r.result.Messages, mr)
+	} else {
+		r.result.MessagesOmitted++
+	}
+	if dedupID != "" {
+		r.published = append(r.published, dedupID)
+	}
+}
+
+// keepMessage diz se o resultado entra na resposta: as amostras do dry run
+// (já limitadas por DryRunSamples), as falhas até maxReportMessages e os
+// sucessos até ReportMessages
+func (r *run) keepMessage(mr MessageResult) bool {
+	if mr.Payload != nil {
+		return true
+	}
+	if mr.Error != "" {
+		if r.reportedErr >= maxReportMessages {
+			return false
+		}
+		r.reportedErr++
+		return true
+	}
+	if r.reportedOK >= r.p.ReportMessages {
+		return false
+	}
+	r.reportedOK++
+	return true
+}
+
+// resume libera a ordering key pausada pelo Pub/Sub após uma falha, para
+// que as próximas mensagens da key voltem a ser publicadas
+func (r *run) resume(topic *pubsub.Topic, key string) {
+	topic.ResumePublish(key)
+
+	r.pausedMu.Lock()
+	defer r.pausedMu.Unlock()
+	if r.paused == nil {
+		r.paused = map[string]bool{}
+	}
+	if !r.paused[key] {
+		r.paused[key] = true
+		logrus.Warnf("Ordering key %q paused after a failed publish, resumed", key)
+	}
+}
+
+// reject registra o registro inválido no resultado e, se houver tópico de
+// rejeitados, publica o registro junto com os erros de mapeamento ou validação
+func (r *run) reject(rec Record, payload []byte, reason string, reasons []string) {
+	p, result := r.p, r.result
+	id, _ := recordField(rec, p.IDField)
+	logrus.Warnf("Record %q rejected: %v", id, reasons)
+	if len(result.Rejected) < maxReportMessages {
+		result.Rejected = append(result.Rejected, Rejection{ID: id, Reasons: reasons})
+	} else {
+		result.RejectedOmitted++
+	}
+
+	if p.RejectTopic == nil || p.DryRun {
+		return
+	}
+	attrs := map[string]string{"reject_reason": reason}
+	if r.dataKey != nil {
+		// O tópico de rejeitados recebe os campos sensíveis cifrados, como o
+		// principal. Sem o mapeamento aplicado, valem os nomes da origem.
+		fields := p.EncryptFields
+		if reason == "mapping" {
+			fields = p.Mapping.SourceFields(fields)
+		}
+		var encrypted []string
+		var err error
+		if payload, encrypted, err = r.dataKey.EncryptFields(payload, fields); err != nil {
+			logrus.Errorf("Erro ao cifrar o registro rejeitado %q: %v", id, err)
+			atomic.AddUint64(&result.Errors, 1)
+			return
+		}
+		r.dataKey.Annotate(attrs, encrypted)
+	}
+	data, err := json.Marshal(rejectEnvelope{Record: payload, Errors: reasons})
+	if err != nil {
+		logrus.Errorf("Erro ao converter registro rejeitado para JSON: %v", err)
+		atomic.AddUint64(&result.Errors, 1)
+		return
+	}
+
+	res := p.RejectTopic.Publish(r.ctx, &pubsub.Message{
+		Data:       data,
+		Attributes: attrs,
+	})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if _, err := res.Get(r.ctx); err != nil {
+			logrus.Errorf("Failed to publish rejected record %q: %v", id, err)
+			atomic.AddUint64(&result.Errors, 1)
+		}
+	}()
+}
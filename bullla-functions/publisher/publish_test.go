@@ -0,0 +1,221 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/message"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestClient cria um client ligado a um Pub/Sub em memória, com os
+// tópicos informados
+func newTestClient(t *testing.T, topics ...string) *pubsub.Client {
+	t.Helper()
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := pubsub.NewClient(ctx, "projeto", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	// Os tópicos são da instância; param antes do client fechar
+	t.Cleanup(StopTopics)
+	for _, id := range topics {
+		if _, err := c.CreateTopic(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+func TestRunLimitSkipsCheckpoint(t *testing.T) {
+	var conditional []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `[{"id": 1}, {"id": 2}, {"id": 3}, {"id": 4}, {"id": 5}]`)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, "topico")
+	cfg := loadTestConfig(t, map[string]string{
+		"ENDPOINT_SERVER": srv.URL,
+		"TOPIC_ID":        "topico",
+		"STATE_STORE":     "file",
+		"STATE_PATH":      t.TempDir(),
+	})
+	run := func(limit int) *Result {
+		t.Helper()
+		c := *cfg
+		c.Selection.Limit = limit
+		p, err := NewPipeline(&c, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	checkpoint := func() *Checkpoint {
+		t.Helper()
+		state, _ := NewStateStore(cfg)
+		cp, err := state.Load(context.Background(), cfg.CheckpointKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cp
+	}
+
+	// O limite para a leitura: nada de checkpoint nem de validadores
+	for i := 0; i < 2; i++ {
+		result := run(2)
+		if result.Published != 2 || !result.LimitReached || result.Pages != 1 {
+			t.Fatalf("execução %d: published=%d limit_reached=%v pages=%d, want 2, true, 1", i+1, result.Published, result.LimitReached, result.Pages)
+		}
+		if cp := checkpoint(); cp != nil {
+			t.Fatalf("execução %d: checkpoint salvo com o limite: %+v", i+1, *cp)
+		}
+	}
+
+	// Lida até o fim, a origem entra no checkpoint com o ETag
+	if result := run(0); result.Published != 5 || result.LimitReached {
+		t.Fatalf("published=%d limit_reached=%v, want 5, false", result.Published, result.LimitReached)
+	}
+	if cp := checkpoint(); cp == nil || cp.ETag != `"v1"` {
+		t.Fatalf("checkpoint = %+v, want ETag \"v1\"", cp)
+	}
+
+	// E a próxima execução pede a origem condicionalmente
+	if result := run(2); result.Published != 0 {
+		t.Fatalf("published=%d depois do 304, want 0", result.Published)
+	}
+	want := []string{"", "", "", `"v1"`}
+	if fmt.Sprint(conditional) != fmt.Sprint(want) {
+		t.Errorf("If-None-Match = %q, want %q", conditional, want)
+	}
+}
+
+func TestRunSelectionSkipsCheckpoint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 1, "tipo": "a"}, {"id": 2, "tipo": "a"}, {"id": 3, "tipo": "a"}, {"id": 4, "tipo": "a"}, {"id": 5, "tipo": "b"}]`)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, "topico")
+	cfg := loadTestConfig(t, map[string]string{
+		"ENDPOINT_SERVER": srv.URL,
+		"TOPIC_ID":        "topico",
+		"STATE_STORE":     "file",
+		"STATE_PATH":      t.TempDir(),
+		"SINCE_FIELD":     "id",
+	})
+	run := func(sel Selection) *Result {
+		t.Helper()
+		c := *cfg
+		c.Selection = sel
+		c.Filters = map[string]interface{}{"tipo": "a"}
+		p, err := NewPipeline(&c, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	checkpoint := func() *Checkpoint {
+		t.Helper()
+		state, _ := NewStateStore(cfg)
+		cp, err := state.Load(context.Background(), cfg.CheckpointKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cp
+	}
+
+	// No modo tail a leitura vai até o fim, mas o limite e o offset deixam
+	// registros de fora; a amostragem também
+	for _, sel := range []Selection{
+		{Mode: selectTail, Limit: 2},
+		{Mode: selectTail, Offset: 1},
+		{Mode: selectHead, Offset: 1},
+		{Mode: selectHead, Sample: 50},
+	} {
+		result := run(sel)
+		if result.LimitReached {
+			t.Errorf("%+v: limit_reached com a leitura até o fim", sel)
+		}
+		if cp := checkpoint(); cp != nil {
+			t.Fatalf("%+v: checkpoint salvo com a seleção: %+v", sel, *cp)
+		}
+	}
+
+	// Sem seleção, a high-water mark é a do maior registro publicado: o
+	// filtrado não conta
+	if result := run(Selection{Mode: selectHead}); result.Published != 4 {
+		t.Fatalf("published=%d, want 4", result.Published)
+	}
+	if cp := checkpoint(); cp == nil || cp.HighWater != "4" {
+		t.Fatalf("checkpoint = %+v, want high-water 4", cp)
+	}
+}
+
+func TestRunPayloadCompression(t *testing.T) {
+	long := strings.Repeat("descrição longa ", 20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"id": 1}, {"id": 2, "texto": %q}]`, long)
+	}))
+	defer srv.Close()
+
+	cfg := loadTestConfig(t, map[string]string{
+		"ENDPOINT_SERVER":       srv.URL,
+		"TOPIC_ID":              "topico",
+		"PAYLOAD_COMPRESSION":   "gzip",
+		"COMPRESSION_THRESHOLD": "64",
+		"DRY_RUN":               "true",
+	})
+	p, err := NewPipeline(cfg, newTestClient(t, "topico"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Messages) != 2 {
+		t.Fatalf("%d mensagens, want 2", len(result.Messages))
+	}
+
+	// Abaixo do limite o payload vai em JSON, sem o atributo
+	small := result.Messages[0]
+	if _, ok := small.Attributes[message.AttrContentEncoding]; ok || small.RawSize != 0 {
+		t.Errorf("registro 1: content-encoding %q, raw_size %d; want sem compressão", small.Attributes[message.AttrContentEncoding], small.RawSize)
+	}
+	// Acima dele, comprimido: a amostra mostra o JSON e o tamanho comprimido
+	big := result.Messages[1]
+	if big.Attributes[message.AttrContentEncoding] != message.Gzip || big.Size >= big.RawSize || big.RawSize != len(big.Payload) {
+		t.Errorf("registro 2: content-encoding %q, size %d, raw_size %d, payload %d bytes",
+			big.Attributes[message.AttrContentEncoding], big.Size, big.RawSize, len(big.Payload))
+	}
+}
@@ -0,0 +1,208 @@
+// Package pubsettings lê e valida o batching e o flow control da publicação
+// no Pub/Sub (PUBLISH_* e FLOW_CONTROL_*). Ao contrário do pacote publisher,
+// não registra nenhuma function, então as outras functions do repositório
+// podem importá-lo e publicar com a mesma configuração.
+package pubsettings
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// Comportamentos do flow control ao atingir os limites (FLOW_CONTROL_BEHAVIOR)
+const (
+	flowControlBlock       = "block"
+	flowControlIgnore      = "ignore"
+	flowControlSignalError = "signal_error"
+)
+
+// PublishConfig é o batching e o flow control da publicação, lidos do
+// ambiente (PUBLISH_* e FLOW_CONTROL_*) e aplicados a todos os tópicos
+type PublishConfig struct {
+	// Um lote é enviado ao atingir DelayThreshold, CountThreshold mensagens
+	// ou ByteThreshold bytes, o que vier primeiro
+	DelayThreshold time.Duration
+	CountThreshold int
+	ByteThreshold  int
+	// NumGoroutines envia os lotes em paralelo (0 = padrão da biblioteca,
+	// 25 × GOMAXPROCS)
+	NumGoroutines int
+	// Timeout de cada envio ao Pub/Sub, incluindo as retentativas
+	Timeout time.Duration
+	// BufferedByteLimit limita os bytes em memória aguardando envio
+	BufferedByteLimit int
+
+	// Flow control: ao passar de MaxOutstandingMessages ou MaxOutstandingBytes
+	// (0 = sem limite) o Publish bloqueia (block), segue (ignore) ou falha
+	// (signal_error)
+	MaxOutstandingMessages int
+	MaxOutstandingBytes    int
+	LimitExceededBehavior  string
+}
+
+// Load lê as configurações de publicação do ambiente. Sem variáveis, o
+// batching é o padrão da biblioteca e o flow control bloqueia em 100
+// mensagens ou 10MB.
+func Load() (PublishConfig, error) {
+	def := pubsub.DefaultPublishSettings
+	pc := PublishConfig{LimitExceededBehavior: strings.ToLower(getEnv("FLOW_CONTROL_BEHAVIOR", flowControlBlock))}
+
+	var err error
+	if pc.DelayThreshold, err = getEnvDuration("PUBLISH_DELAY_THRESHOLD", def.DelayThreshold); err != nil {
+		return pc, err
+	}
+	if pc.CountThreshold, err = getEnvInt("PUBLISH_COUNT_THRESHOLD", def.CountThreshold); err != nil {
+		return pc, err
+	}
+	if pc.ByteThreshold, err = EnvIntBytes("PUBLISH_BYTE_THRESHOLD", def.ByteThreshold); err != nil {
+		return pc, err
+	}
+	if pc.NumGoroutines, err = getEnvInt("PUBLISH_NUM_GOROUTINES", 0); err != nil {
+		return pc, err
+	}
+	if pc.Timeout, err = getEnvDuration("PUBLISH_TIMEOUT", def.Timeout); err != nil {
+		return pc, err
+	}
+	if pc.BufferedByteLimit, err = EnvIntBytes("PUBLISH_BUFFERED_BYTE_LIMIT", def.BufferedByteLimit); err != nil {
+		return pc, err
+	}
+	if pc.MaxOutstandingMessages, err = getEnvInt("FLOW_CONTROL_MAX_MESSAGES", 100); err != nil {
+		return pc, err
+	}
+	if pc.MaxOutstandingBytes, err = EnvIntBytes("FLOW_CONTROL_MAX_BYTES", 10<<20); err != nil {
+		return pc, err
+	}
+	return pc, pc.Validate()
+}
+
+// EnvIntBytes é o EnvBytes para os campos int do Pub/Sub
+func EnvIntBytes(key string, def int) (int, error) {
+	n, err := EnvBytes(key, int64(def))
+	if err != nil {
+		return 0, err
+	}
+	if n > 1<<31-1 {
+		return 0, fmt.Errorf("%s inválido: %q", key, getEnv(key, ""))
+	}
+	return int(n), nil
+}
+
+// Validate confere os valores contra os limites do Pub/Sub
+func (pc PublishConfig) Validate() error {
+	switch {
+	case pc.DelayThreshold <= 0:
+		return fmt.Errorf("PUBLISH_DELAY_THRESHOLD: deve ser maior que zero")
+	case pc.CountThreshold < 1 || pc.CountThreshold > pubsub.MaxPublishRequestCount:
+		return fmt.Errorf("PUBLISH_COUNT_THRESHOLD: deve estar entre 1 e %d", pubsub.MaxPublishRequestCount)
+	case pc.ByteThreshold < 1 || pc.ByteThreshold > pubsub.MaxPublishRequestBytes:
+		return fmt.Errorf("PUBLISH_BYTE_THRESHOLD: deve estar entre 1 e %d bytes", int(pubsub.MaxPublishRequestBytes))
+	case pc.NumGoroutines < 0:
+		return fmt.Errorf("PUBLISH_NUM_GOROUTINES: deve ser maior ou igual a zero")
+	case pc.Timeout <= 0:
+		return fmt.Errorf("PUBLISH_TIMEOUT: deve ser maior que zero")
+	case pc.BufferedByteLimit < pc.ByteThreshold:
+		return fmt.Errorf("PUBLISH_BUFFERED_BYTE_LIMIT: deve ser maior ou igual a PUBLISH_BYTE_THRESHOLD")
+	case pc.MaxOutstandingMessages < 0:
+		return fmt.Errorf("FLOW_CONTROL_MAX_MESSAGES: deve ser maior ou igual a zero")
+	case pc.MaxOutstandingBytes < 0:
+		return fmt.Errorf("FLOW_CONTROL_MAX_BYTES: deve ser maior ou igual a zero")
+	}
+	if _, err := flowControlBehavior(pc.LimitExceededBehavior); err != nil {
+		return err
+	}
+	return nil
+}
+
+func flowControlBehavior(name string) (pubsub.LimitExceededBehavior, error) {
+	switch name {
+	case flowControlBlock:
+		return pubsub.FlowControlBlock, nil
+	case flowControlIgnore:
+		return pubsub.FlowControlIgnore, nil
+	case flowControlSignalError:
+		return pubsub.FlowControlSignalError, nil
+	default:
+		return 0, fmt.Errorf("FLOW_CONTROL_BEHAVIOR: deve ser %s, %s ou %s", flowControlBlock, flowControlIgnore, flowControlSignalError)
+	}
+}
+
+// Apply configura a publicação no tópico; a configuração já foi validada
+func (pc PublishConfig) Apply(t *pubsub.Topic) {
+	behavior, _ := flowControlBehavior(pc.LimitExceededBehavior)
+	t.PublishSettings.DelayThreshold = pc.DelayThreshold
+	t.PublishSettings.CountThreshold = pc.CountThreshold
+	t.PublishSettings.ByteThreshold = pc.ByteThreshold
+	t.PublishSettings.NumGoroutines = pc.NumGoroutines
+	t.PublishSettings.Timeout = pc.Timeout
+	t.PublishSettings.BufferedByteLimit = pc.BufferedByteLimit
+	t.PublishSettings.FlowControlSettings = pubsub.FlowControlSettings{
+		MaxOutstandingMessages: pc.MaxOutstandingMessages,
+		MaxOutstandingBytes:    pc.MaxOutstandingBytes,
+		LimitExceededBehavior:  behavior,
+	}
+}
+
+func (pc PublishConfig) String() string {
+	return fmt.Sprintf("delay=%s count=%d bytes=%d goroutines=%d timeout=%s buffered=%d flow_control=%s(messages=%d bytes=%d)",
+		pc.DelayThreshold, pc.CountThreshold, pc.ByteThreshold, pc.NumGoroutines, pc.Timeout, pc.BufferedByteLimit,
+		pc.LimitExceededBehavior, pc.MaxOutstandingMessages, pc.MaxOutstandingBytes)
+}
+
+// EnvBytes lê um tamanho em bytes, aceitando os sufixos KB, MB e GB (base 1024)
+func EnvBytes(key string, def int64) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(os.Getenv(key)))
+	if v == "" {
+		return def, nil
+	}
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(v, u.suffix) {
+			v, unit = strings.TrimSpace(strings.TrimSuffix(v, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s inválido: %q", key, os.Getenv(key))
+	}
+	return n * unit, nil
+}
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func getEnvDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s inválido: %w", key, err)
+	}
+	return d, nil
+}
+
+func getEnvInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s inválido: %w", key, err)
+	}
+	return n, nil
+}
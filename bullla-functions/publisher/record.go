@@ -0,0 +1,349 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Record é um registro lido da origem, sem estrutura fixa. Números chegam
+// como json.Number e campos aninhados como Record ou map[string]interface{}.
+type Record map[string]interface{}
+
+// lookup devolve o valor do caminho informado, separado por pontos (ex.
+// "customer.address.city")
+func (r Record) lookup(path string) (interface{}, bool) {
+	var cur interface{} = map[string]interface{}(r)
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := asObject(cur)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// set grava v no caminho informado, criando os objetos intermediários
+func (r Record) set(path string, v interface{}) {
+	keys := strings.Split(path, ".")
+	obj := map[string]interface{}(r)
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := asObject(obj[key])
+		if !ok {
+			next = map[string]interface{}{}
+			obj[key] = next
+		}
+		obj = next
+	}
+	obj[keys[len(keys)-1]] = v
+}
+
+// remove apaga o caminho informado, se existir
+func (r Record) remove(path string) {
+	keys := strings.Split(path, ".")
+	obj := map[string]interface{}(r)
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := asObject(obj[key])
+		if !ok {
+			return
+		}
+		obj = next
+	}
+	delete(obj, keys[len(keys)-1])
+}
+
+func asObject(v interface{}) (map[string]interface{}, bool) {
+	switch o := v.(type) {
+	case map[string]interface{}:
+		return o, true
+	case Record:
+		return o, true
+	default:
+		return nil, false
+	}
+}
+
+// clone copia o registro e os objetos aninhados, para que o mapeamento não
+// altere o registro lido da origem
+func (r Record) clone() Record {
+	return Record(cloneObject(r))
+}
+
+func cloneObject(obj map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		if nested, ok := asObject(v); ok {
+			v = cloneObject(nested)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// recordField devolve como texto o campo (ou caminho) do registro. Campos
+// ausentes ou null devolvem false.
+func recordField(rec Record, path string) (string, bool) {
+	v, ok := rec.lookup(path)
+	if !ok || v == nil {
+		return "", false
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case map[string]interface{}, Record, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+// Tipos aceitos na conversão de campos do mapeamento
+const (
+	typeString = "string"
+	typeInt    = "int"
+	typeFloat  = "float"
+	typeBool   = "bool"
+)
+
+// Mapping descreve como montar o payload publicado a partir do registro da
+// origem. Sem mapeamento o registro é publicado como foi lido.
+type Mapping struct {
+	// Fields são copiados (e renomeados/convertidos) para o payload
+	Fields []FieldMapping `json:"fields"`
+	// KeepUnmapped copia também os campos não citados em Fields
+	KeepUnmapped bool `json:"keep_unmapped,omitempty"`
+	// Drop remove campos do payload final (útil com KeepUnmapped)
+	Drop []string `json:"drop,omitempty"`
+}
+
+// FieldMapping mapeia um campo da origem para um campo do payload. From e
+// To aceitam caminhos aninhados separados por pontos.
+type FieldMapping struct {
+	From string `json:"from"`
+	// To é o nome no payload; padrão: o mesmo de From
+	To string `json:"to,omitempty"`
+	// Type converte o valor: string, int, float ou bool (vazio mantém o original)
+	Type string `json:"type,omitempty"`
+	// Default é usado quando o campo está ausente ou é null
+	Default interface{} `json:"default,omitempty"`
+	// Required rejeita o registro quando o campo (e o default) está ausente
+	Required bool `json:"required,omitempty"`
+}
+
+// Os mapeamentos de arquivo são lidos uma vez por instância
+var (
+	mappingsMu sync.Mutex
+	mappings   = map[string]*Mapping{}
+)
+
+// loadMapping lê o mapeamento inline (MAPPING) ou do arquivo (MAPPING_FILE).
+// Sem nenhum dos dois devolve nil, ou seja, o registro passa inalterado.
+func loadMapping(inline, path string) (*Mapping, error) {
+	if inline != "" {
+		return parseMapping([]byte(inline), "MAPPING")
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	mappingsMu.Lock()
+	defer mappingsMu.Unlock()
+	if m, ok := mappings[path]; ok {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler MAPPING_FILE: %w", err)
+	}
+	m, err := parseMapping(data, "MAPPING_FILE")
+	if err != nil {
+		return nil, err
+	}
+	mappings[path] = m
+	return m, nil
+}
+
+func parseMapping(data []byte, source string) (*Mapping, error) {
+	var m Mapping
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s inválido: %w", source, err)
+	}
+	for i, f := range m.Fields {
+		if f.From == "" {
+			return nil, fmt.Errorf("%s inválido: fields[%d] sem from", source, i)
+		}
+		switch f.Type {
+		case "", typeString, typeInt, typeFloat, typeBool:
+		default:
+			return nil, fmt.Errorf("%s inválido: tipo %q em %s", source, f.Type, f.From)
+		}
+	}
+	return &m, nil
+}
+
+// Apply monta o payload a partir do registro. O erro indica um campo
+// obrigatório ausente ou um valor que não pôde ser convertido.
+func (m *Mapping) Apply(rec Record) (Record, error) {
+	if m == nil {
+		return rec, nil
+	}
+
+	out := Record{}
+	if m.KeepUnmapped {
+		out = rec.clone()
+		for _, f := range m.Fields {
+			out.remove(f.From)
+		}
+	}
+
+	for _, f := range m.Fields {
+		v, ok := rec.lookup(f.From)
+		if !ok || v == nil {
+			v = f.Default
+		}
+		if v == nil {
+			if f.Required {
+				return nil, fmt.Errorf("campo %s ausente", f.From)
+			}
+			continue
+		}
+		v, err := coerce(v, f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("campo %s: %w", f.From, err)
+		}
+		to := f.To
+		if to == "" {
+			to = f.From
+		}
+		out.set(to, v)
+	}
+
+	for _, path := range m.Drop {
+		out.remove(path)
+	}
+	return out, nil
+}
+
+// SourceFields devolve os caminhos da origem que dão origem aos campos
+// informados (caminhos do payload mapeado), além dos próprios caminhos
+func (m *Mapping) SourceFields(fields []string) []string {
+	out := append([]string(nil), fields...)
+	if m == nil {
+		return out
+	}
+	seen := map[string]bool{}
+	for _, f := range fields {
+		seen[f] = true
+	}
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			out = append(out, path)
+		}
+	}
+	for _, field := range fields {
+		for _, f := range m.Fields {
+			to := f.To
+			if to == "" {
+				to = f.From
+			}
+			switch {
+			case field == to, strings.HasPrefix(to, field+"."):
+				add(f.From)
+			case strings.HasPrefix(field, to+"."):
+				add(f.From + field[len(to):])
+			}
+		}
+	}
+	return out
+}
+
+// coerce converte v para o tipo informado
+func coerce(v interface{}, typ string) (interface{}, error) {
+	switch typ {
+	case "":
+		return v, nil
+	case typeString:
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case json.Number:
+			return v.String(), nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+	case typeInt:
+		s, ok := numberText(v)
+		if !ok {
+			break
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+		// Aceita "10.0", mas não "10.5"
+		if f, err := strconv.ParseFloat(s, 64); err == nil && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return int64(f), nil
+		}
+		return nil, fmt.Errorf("%q não é um inteiro", s)
+	case typeFloat:
+		s, ok := numberText(v)
+		if !ok {
+			break
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q não é um número", s)
+		}
+		return f, nil
+	case typeBool:
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("%q não é um booleano", v)
+			}
+			return b, nil
+		case json.Number:
+			switch v.String() {
+			case "0":
+				return false, nil
+			case "1":
+				return true, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("não é possível converter %v (%T) para %s", v, v, typ)
+}
+
+// numberText devolve a representação textual de um valor numérico ou string
+func numberText(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String(), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case string:
+		return strings.TrimSpace(v), true
+	default:
+		return "", false
+	}
+}
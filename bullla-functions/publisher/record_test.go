@@ -0,0 +1,101 @@
+package publisher
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMappingApply(t *testing.T) {
+	m, err := parseMapping([]byte(`{
+		"fields": [
+			{"from": "id", "type": "int", "required": true},
+			{"from": "customer.name", "to": "cliente"},
+			{"from": "amount", "to": "valor", "type": "float"},
+			{"from": "active", "type": "bool", "default": "true"},
+			{"from": "code", "type": "string"}
+		],
+		"keep_unmapped": true,
+		"drop": ["secret"]
+	}`), "MAPPING")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := Record{
+		"id":       json.Number("10.0"),
+		"customer": map[string]interface{}{"name": "Ana", "city": "SP"},
+		"amount":   "12.5",
+		"code":     json.Number("7"),
+		"secret":   "x",
+		"extra":    "y",
+	}
+	got, err := m.Apply(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Record{
+		"id":       int64(10),
+		"cliente":  "Ana",
+		"customer": map[string]interface{}{"city": "SP"},
+		"valor":    12.5,
+		"active":   true,
+		"code":     "7",
+		"extra":    "y",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply = %#v, want %#v", got, want)
+	}
+	// O registro da origem não é alterado
+	if _, ok := rec.lookup("customer.name"); !ok {
+		t.Error("Apply alterou o registro da origem")
+	}
+
+	for _, bad := range []Record{
+		{"amount": "1"},
+		{"id": "10.5"},
+		{"id": json.Number("1"), "active": "talvez"},
+	} {
+		if _, err := m.Apply(bad); err == nil {
+			t.Errorf("Apply(%v) aceitou o registro", bad)
+		}
+	}
+}
+
+func TestParseMappingInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"fields": [{"to": "x"}]}`,
+		`{"fields": [{"from": "x", "type": "date"}]}`,
+		`{"fields": [], "extra": true}`,
+	} {
+		if _, err := parseMapping([]byte(data), "MAPPING"); err == nil {
+			t.Errorf("parseMapping(%s) aceitou o mapeamento", data)
+		}
+	}
+}
+
+func TestCoerce(t *testing.T) {
+	tests := []struct {
+		v       interface{}
+		typ     string
+		want    interface{}
+		wantErr bool
+	}{
+		{json.Number("3"), typeInt, int64(3), false},
+		{" 42 ", typeInt, int64(42), false},
+		{1.5, typeInt, nil, true},
+		{json.Number("2.5"), typeFloat, 2.5, false},
+		{"abc", typeFloat, nil, true},
+		{true, typeString, "true", false},
+		{2.0, typeString, "2", false},
+		{json.Number("0"), typeBool, false, false},
+		{json.Number("2"), typeBool, nil, true},
+		{"TRUE", typeBool, true, false},
+		{map[string]interface{}{}, typeString, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := coerce(tt.v, tt.typ)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("coerce(%#v, %s) = %#v, %v; want %#v, erro %v", tt.v, tt.typ, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
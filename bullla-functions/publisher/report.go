@@ -0,0 +1,164 @@
+package publisher
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MessageResult é o resultado da publicação de um registro: o ID da
+// mensagem no Pub/Sub ou o erro
+type MessageResult struct {
+	Index     int    `json:"index"` // ordem de publicação
+	ID        string `json:"id,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Topic     string `json:"topic"`
+	Size      int    `json:"size"`               // bytes do payload publicado
+	RawSize   int    `json:"raw_size,omitempty"` // bytes do JSON, antes da codificação e da compressão
+	MessageID string `json:"message_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// ClaimCheck é a key do blob com o payload, quando publicado por referência
+	ClaimCheck string `json:"claim_check,omitempty"`
+
+	// Amostra do que seria publicado, só em dry run
+	Payload     json.RawMessage   `json:"payload,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	OrderingKey string            `json:"ordering_key,omitempty"`
+}
+
+// Durations é o tempo gasto em cada etapa da execução. A leitura e o
+// processamento se intercalam, então Fetch é só o tempo esperando a origem
+// e Publish inclui a espera pelas confirmações depois da leitura.
+type Durations struct {
+	Fetch      time.Duration
+	Process    time.Duration
+	Publish    time.Duration
+	Checkpoint time.Duration // checkpoint, deduplicação e limpeza dos blobs
+	Total      time.Duration
+}
+
+// MarshalJSON escreve as durações em milissegundos
+func (d Durations) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	return json.Marshal(map[string]float64{
+		"fetch_ms":      ms(d.Fetch),
+		"process_ms":    ms(d.Process),
+		"publish_ms":    ms(d.Publish),
+		"checkpoint_ms": ms(d.Checkpoint),
+		"total_ms":      ms(d.Total),
+	})
+}
+
+// ReportTotals são os contadores da execução
+type ReportTotals struct {
+	FetchStats
+	Selected  uint64 `json:"selected"`
+	Filtered  uint64 `json:"filtered"`
+	Duplicate uint64 `json:"duplicate"`
+	Skipped   uint64 `json:"skipped"`
+	Rejected  int    `json:"rejected"`
+	Published uint64 `json:"published"`
+	Errors    uint64 `json:"errors"`
+
+	ClaimChecks  uint64 `json:"claim_checks,omitempty"`
+	BlobsCleaned int    `json:"blobs_cleaned,omitempty"`
+}
+
+// Report é a resposta da function: um único documento JSON com o status
+// geral, os totais e o resultado de cada registro
+type Report struct {
+	// Status é 200 quando tudo foi publicado, 207 quando parte falhou ou foi
+	// rejeitada e 500 quando nada foi publicado. Uma falha na origem sem
+	// nenhuma publicação mantém o status de HTTPStatus.
+	Status     int                      `json:"status"`
+	RunID      string                   `json:"run_id,omitempty"`
+	DryRun     bool                     `json:"dry_run,omitempty"`
+	Error      string                   `json:"error,omitempty"`
+	Totals     ReportTotals             `json:"totals"`
+	Durations  Durations                `json:"durations"`
+	Sources    map[string]*SourceResult `json:"sources,omitempty"`
+	Topics     map[string]*TopicResult  `json:"topics,omitempty"`
+	PausedKeys []string                 `json:"paused_keys,omitempty"`
+	Rejected   []Rejection              `json:"rejected,omitempty"`
+	Messages   []MessageResult          `json:"messages,omitempty"`
+
+	// LimitReached indica que o LIMIT parou a leitura e o checkpoint não avançou
+	LimitReached bool `json:"limit_reached,omitempty"`
+	// MessagesOmitted conta os resultados que não couberam em Messages
+	MessagesOmitted int `json:"messages_omitted,omitempty"`
+	// RejectedOmitted conta as rejeições que não couberam em Rejected
+	RejectedOmitted int `json:"rejected_omitted,omitempty"`
+}
+
+// NewReport monta a resposta a partir do resultado e do erro de Run
+func NewReport(result *Result, err error) *Report {
+	rep := &Report{Status: http.StatusOK}
+	if err != nil {
+		rep.Error = err.Error()
+	}
+	if result == nil {
+		if err != nil {
+			rep.Status = HTTPStatus(err)
+		}
+		return rep
+	}
+
+	rep.RunID = result.RunID
+	rep.DryRun = result.DryRun
+	rep.LimitReached = result.LimitReached
+	rep.Totals = ReportTotals{
+		FetchStats: result.FetchStats,
+		Selected:   result.Selected,
+		Filtered:   result.Filtered,
+		Duplicate:  result.Duplicate,
+		Skipped:    result.Skipped,
+		Rejected:   result.RejectedCount(),
+		Published:  result.Published,
+		Errors:     result.Errors,
+
+		ClaimChecks:  result.ClaimChecks,
+		BlobsCleaned: result.BlobsCleaned,
+	}
+	rep.Durations = result.Durations
+	rep.Sources = result.Sources
+	rep.Topics = result.Topics
+	rep.PausedKeys = result.PausedKeys
+	rep.Rejected = result.Rejected
+	rep.Messages = result.Messages
+	rep.MessagesOmitted = result.MessagesOmitted
+	rep.RejectedOmitted = result.RejectedOmitted
+
+	delivered := result.Published
+	if result.DryRun {
+		delivered = result.Selected
+	}
+	failed := result.Errors + uint64(result.RejectedCount())
+	switch {
+	case err != nil && delivered == 0:
+		// Cada tipo de falha da origem vira um status distinto (503, 504, 424, 502)
+		rep.Status = HTTPStatus(err)
+	case err != nil, failed > 0 && delivered > 0:
+		rep.Status = http.StatusMultiStatus
+	case failed > 0:
+		rep.Status = http.StatusInternalServerError
+	}
+	return rep
+}
+
+// ErrorReport é a resposta de uma falha antes da execução
+func ErrorReport(status int, err error) *Report {
+	return &Report{Status: status, Error: err.Error()}
+}
+
+// Write escreve o relatório na resposta, com o Status como código HTTP
+func (rep *Report) Write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(rep.Status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		logrus.Errorf("Failed to write report: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewReportStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *Result
+		err    error
+		want   int
+	}{
+		{"sem resultado", nil, ErrCircuitOpen, http.StatusServiceUnavailable},
+		{"tudo publicado", &Result{Selected: 2, Published: 2}, nil, http.StatusOK},
+		{"parte com erro", &Result{Selected: 2, Published: 1, Errors: 1}, nil, http.StatusMultiStatus},
+		{"parte rejeitada", &Result{Selected: 1, Published: 1, Rejected: []Rejection{{ID: "2"}}}, nil, http.StatusMultiStatus},
+		{"só rejeições omitidas", &Result{Selected: 1, Published: 1, RejectedOmitted: 1}, nil, http.StatusMultiStatus},
+		{"nada publicado", &Result{Selected: 1, Errors: 1}, nil, http.StatusInternalServerError},
+		{"origem falhou no meio", &Result{Selected: 1, Published: 1}, ErrBodyTooLarge, http.StatusMultiStatus},
+		{"origem falhou no início", &Result{}, ErrBodyTooLarge, http.StatusBadGateway},
+		{"dry run", &Result{DryRun: true, Selected: 2}, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewReport(tt.result, tt.err).Status; got != tt.want {
+				t.Errorf("Status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	rep := NewReport(&Result{RunID: "r1", Selected: 3, Published: 2, Rejected: []Rejection{{ID: "3", Reasons: []string{"motivo"}}}, RejectedOmitted: 4}, nil)
+	w := httptest.NewRecorder()
+	rep.Write(w)
+	if w.Code != http.StatusMultiStatus || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("code=%d content-type=%q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var got struct {
+		Status          int `json:"status"`
+		RejectedOmitted int `json:"rejected_omitted"`
+		Totals          struct {
+			Rejected  int `json:"rejected"`
+			Published int `json:"published"`
+		} `json:"totals"`
+		Rejected []Rejection `json:"rejected"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != w.Code || got.Totals.Rejected != 5 || got.RejectedOmitted != 4 || len(got.Rejected) != 1 || got.Totals.Published != 2 {
+		t.Errorf("relatório = %+v", got)
+	}
+}
+
+func TestRunRejectedCapped(t *testing.T) {
+	n := maxReportMessages + 5
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "[")
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"id": %d}`, i)
+		}
+		fmt.Fprint(w, "]")
+	}))
+	defer srv.Close()
+
+	// Sem o campo da ordering key, todos os registros são rejeitados
+	cfg := loadTestConfig(t, map[string]string{
+		"ENDPOINT_SERVER": srv.URL,
+		"TOPIC_ID":        "topico",
+		"ORDERING_KEY":    "customer",
+		"DRY_RUN":         "true",
+	})
+	p, err := NewPipeline(cfg, newTestClient(t, "topico"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rejected) != maxReportMessages || result.RejectedOmitted != 5 || result.RejectedCount() != n {
+		t.Errorf("rejected=%d omitted=%d, want %d, 5", len(result.Rejected), result.RejectedOmitted, maxReportMessages)
+	}
+	if rep := NewReport(result, nil); rep.Totals.Rejected != n || rep.Status != http.StatusInternalServerError {
+		t.Errorf("totals.rejected=%d status=%d, want %d, 500", rep.Totals.Rejected, rep.Status, n)
+	}
+}
@@ -0,0 +1,187 @@
+package publisher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxRequestBody limita o corpo aceito no POST que dispara a function
+const maxRequestBody = 1 << 20
+
+// RunRequest são os parâmetros opcionais de uma execução, enviados no corpo
+// do POST que dispara a function. Valem só para aquela invocação.
+type RunRequest struct {
+	// Params são adicionados à query de todas as requisições à origem
+	Params map[string]string `json:"params,omitempty"`
+	// Topic substitui o TOPIC_ID
+	Topic string `json:"topic,omitempty"`
+	// Limit é o máximo de registros publicados (0 = sem limite)
+	Limit int `json:"limit,omitempty"`
+	// Offset descarta os primeiros (ou, no modo tail, os últimos) registros
+	Offset int `json:"offset,omitempty"`
+	// Sample é o percentual (0-100) de registros publicados, amostrados pelo ID
+	Sample float64 `json:"sample,omitempty"`
+	// Mode é head ou tail
+	Mode string `json:"mode,omitempty"`
+	// Filters publica só os registros cujos campos têm exatamente esses valores
+	Filters map[string]interface{} `json:"filters,omitempty"`
+	// Filter é uma expressão CEL sobre record que substitui o FILTER
+	Filter string `json:"filter,omitempty"`
+	// DryRun lê e processa os registros sem publicar
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// ParseRunRequest lê e valida o corpo da requisição. Corpo vazio equivale a
+// nenhum parâmetro; campos desconhecidos são rejeitados.
+func ParseRunRequest(r *http.Request) (*RunRequest, error) {
+	rr := &RunRequest{}
+	if r.Body == nil {
+		return rr, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o corpo: %w", err)
+	}
+	if len(body) > maxRequestBody {
+		return nil, fmt.Errorf("corpo maior que %d bytes", maxRequestBody)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return rr, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(rr); err != nil {
+		return nil, fmt.Errorf("JSON inválido: %w", err)
+	}
+	if err := rr.Validate(); err != nil {
+		return nil, err
+	}
+	return rr, nil
+}
+
+// topicIDPattern segue as regras de nome de tópico do Pub/Sub
+var topicIDPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9\-_.~+%]{2,254}$`)
+
+// Validate confere os parâmetros da execução
+func (rr *RunRequest) Validate() error {
+	for k := range rr.Params {
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("params: nome de parâmetro vazio")
+		}
+	}
+	if rr.Topic != "" && (!topicIDPattern.MatchString(rr.Topic) || strings.HasPrefix(rr.Topic, "goog")) {
+		return fmt.Errorf("topic: nome de tópico inválido %q", rr.Topic)
+	}
+	if err := (Selection{Limit: rr.Limit, Offset: rr.Offset, Sample: rr.Sample, Mode: rr.Mode}).Validate(); err != nil {
+		return err
+	}
+	for field, value := range rr.Filters {
+		if field == "" {
+			return fmt.Errorf("filters: nome de campo vazio")
+		}
+		switch value.(type) {
+		case nil, string, float64, bool:
+		default:
+			return fmt.Errorf("filters: o valor de %s deve ser string, número, booleano ou null", field)
+		}
+	}
+	if rr.Filter != "" {
+		if _, err := compileFilter(rr.Filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply sobrepõe os parâmetros da execução à configuração
+func (rr *RunRequest) Apply(cfg *Config) {
+	if len(rr.Params) > 0 {
+		params := make(map[string]string, len(cfg.QueryParams)+len(rr.Params))
+		for k, v := range cfg.QueryParams {
+			params[k] = v
+		}
+		for k, v := range rr.Params {
+			params[k] = v
+		}
+		cfg.QueryParams = params
+	}
+	if rr.Topic != "" {
+		if cfg.CheckpointKey == cfg.TopicID {
+			// Cada tópico tem o próprio checkpoint, a não ser que CHECKPOINT_KEY diga outra coisa
+			cfg.CheckpointKey = rr.Topic
+		}
+		cfg.TopicID = rr.Topic
+	}
+	if rr.Limit > 0 {
+		cfg.Selection.Limit = rr.Limit
+	}
+	if rr.Offset > 0 {
+		cfg.Selection.Offset = rr.Offset
+	}
+	if rr.Sample > 0 {
+		cfg.Selection.Sample = rr.Sample
+	}
+	if rr.Mode != "" {
+		cfg.Selection.Mode = rr.Mode
+	}
+	if len(rr.Filters) > 0 {
+		cfg.Filters = rr.Filters
+	}
+	if rr.Filter != "" {
+		cfg.Filter = rr.Filter
+	}
+	if rr.DryRun {
+		cfg.DryRun = true
+	}
+	if len(rr.Params) > 0 || len(rr.Filters) > 0 || rr.Filter != "" {
+		// Outros params ou filtros são outro dataset: o checkpoint e os IDs
+		// deduplicados não podem ser os mesmos da configuração de base
+		cfg.CheckpointKey += "." + datasetHash(cfg)
+	}
+}
+
+// datasetHash resume os parâmetros e filtros efetivos de cfg. json.Marshal
+// ordena as chaves dos maps, então o mesmo dataset dá sempre o mesmo hash.
+func datasetHash(cfg *Config) string {
+	data, _ := json.Marshal(struct {
+		Params  map[string]string      `json:"params,omitempty"`
+		Filters map[string]interface{} `json:"filters,omitempty"`
+		Filter  string                 `json:"filter,omitempty"`
+	}{cfg.QueryParams, cfg.Filters, cfg.Filter})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// matchFilters diz se rec tem todos os valores exigidos em filters
+func matchFilters(rec Record, filters map[string]interface{}) bool {
+	for field, want := range filters {
+		got, ok := recordField(rec, field)
+		if want == nil {
+			if ok {
+				return false
+			}
+			continue
+		}
+		if got != filterValue(want) {
+			return false
+		}
+	}
+	return true
+}
+
+func filterValue(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,50 @@
+package publisher
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyCheckpointKey(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			TopicID:       "topico",
+			CheckpointKey: "topico",
+			QueryParams:   map[string]string{"status": "ativo"},
+		}
+	}
+	apply := func(rr RunRequest) string {
+		cfg := base()
+		rr.Apply(cfg)
+		return cfg.CheckpointKey
+	}
+
+	// Seleção e dry run leem o mesmo dataset
+	if key := apply(RunRequest{Limit: 5, DryRun: true}); key != "topico" {
+		t.Errorf("limit: CheckpointKey = %q, want %q", key, "topico")
+	}
+	if key := apply(RunRequest{Topic: "outro"}); key != "outro" {
+		t.Errorf("topic: CheckpointKey = %q, want %q", key, "outro")
+	}
+
+	// Params e filtros mudam o dataset, e a chave acompanha os valores efetivos
+	keys := map[string]string{
+		"params":  apply(RunRequest{Params: map[string]string{"dia": "1"}}),
+		"params2": apply(RunRequest{Params: map[string]string{"dia": "2"}}),
+		"filters": apply(RunRequest{Filters: map[string]interface{}{"tipo": "a"}}),
+		"filter":  apply(RunRequest{Filter: "record.amount > 100"}),
+	}
+	seen := map[string]string{}
+	for name, key := range keys {
+		if !strings.HasPrefix(key, "topico.") || safeKey(key) != key {
+			t.Errorf("%s: CheckpointKey = %q, want topico.<hash>", name, key)
+		}
+		if other, ok := seen[key]; ok {
+			t.Errorf("%s e %s têm a mesma CheckpointKey %q", name, other, key)
+		}
+		seen[key] = name
+	}
+	if key := apply(RunRequest{Params: map[string]string{"dia": "1"}}); key != keys["params"] {
+		t.Errorf("CheckpointKey = %q na repetição, want %q", key, keys["params"])
+	}
+}
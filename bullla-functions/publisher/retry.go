@@ -0,0 +1,279 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RetryConfig controla as tentativas de cada requisição à origem e o
+// circuit breaker compartilhado entre invocações
+type RetryConfig struct {
+	MaxAttempts    int
+	AttemptTimeout time.Duration
+	TotalTimeout   time.Duration
+	BackoffInitial time.Duration
+	BackoffMax     time.Duration
+
+	// BreakerThreshold falhas seguidas abrem o circuito por BreakerCooldown (0 desliga)
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+var (
+	// ErrCircuitOpen indica que a origem falhou seguidamente e está sendo evitada
+	ErrCircuitOpen = errors.New("circuit breaker aberto: origem indisponível")
+	// ErrRetryBudget indica que o tempo total das tentativas se esgotou
+	ErrRetryBudget = errors.New("tempo total das tentativas esgotado")
+	// errAttemptTimeout indica que a origem não respondeu dentro do tempo da tentativa
+	errAttemptTimeout = errors.New("tentativa excedeu o tempo limite")
+)
+
+// SourceError é a falha definitiva de uma requisição à origem
+type SourceError struct {
+	StatusCode int // 0 quando não houve resposta
+	Attempts   int
+	Err        error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("origem falhou após %d tentativa(s): %v", e.Attempts, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatus traduz o erro de uma execução no status devolvido pela function
+func HTTPStatus(err error) int {
+	var srcErr *SourceError
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrBodyTooLarge):
+		return http.StatusBadGateway
+	case errors.Is(err, ErrRetryBudget), errors.Is(err, errAttemptTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &srcErr):
+		if srcErr.StatusCode >= 400 && srcErr.StatusCode < 500 && !retryableStatus(srcErr.StatusCode) {
+			// A origem recusou a requisição: repetir não adianta
+			return http.StatusFailedDependency
+		}
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// retryableStatus diz se vale repetir a requisição: 5xx, 408 e 429
+func retryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+// do executa req com backoff exponencial e jitter, respeitando Retry-After,
+// o tempo de cada tentativa, o tempo total e o circuit breaker da origem.
+// Só respostas 2xx/3xx são devolvidas; o corpo ainda não foi lido.
+func (s *httpSource) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	rc := s.cfg.Retry
+	cb := breakerFor(req.URL.Host, rc)
+	deadline := time.Now().Add(rc.TotalTimeout)
+
+	for attempt := 1; ; attempt++ {
+		ok, probe := cb.allow()
+		if !ok {
+			return nil, ErrCircuitOpen
+		}
+
+		var wait time.Duration
+		status := 0
+		resp, err := s.attempt(ctx, req, rc.AttemptTimeout)
+		if err == nil {
+			status = resp.StatusCode
+			if !retryableStatus(status) {
+				// A origem respondeu, mesmo que seja um 4xx
+				cb.success()
+				if status >= 400 {
+					resp.Body.Close()
+					return nil, &SourceError{StatusCode: status, Attempts: attempt, Err: fmt.Errorf("recebido código de status %d", status)}
+				}
+				return resp, nil
+			}
+			wait = retryAfter(resp.Header.Get("Retry-After"))
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			err = fmt.Errorf("recebido código de status %d", status)
+		} else if ctx.Err() != nil {
+			// A execução foi cancelada: a tentativa não diz nada sobre a
+			// origem, mas o teste do meio aberto precisa ser liberado
+			if probe {
+				cb.release()
+			}
+			return nil, ctx.Err()
+		}
+		cb.failure()
+
+		if attempt >= rc.MaxAttempts {
+			return nil, &SourceError{StatusCode: status, Attempts: attempt, Err: err}
+		}
+		if wait == 0 {
+			wait = backoff(rc, attempt)
+		}
+		if rc.TotalTimeout > 0 && time.Now().Add(wait).After(deadline) {
+			return nil, &SourceError{StatusCode: status, Attempts: attempt, Err: fmt.Errorf("%w: %v", ErrRetryBudget, err)}
+		}
+
+		logrus.Warnf("Source request failed (attempt %d/%d): %v; retrying in %v", attempt, rc.MaxAttempts, err, wait)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt faz uma tentativa limitada a timeout até a chegada dos headers.
+// A leitura do corpo não tem esse limite, já que depende da publicação.
+func (s *httpSource) attempt(ctx context.Context, req *http.Request, timeout time.Duration) (*http.Response, error) {
+	actx, cancel := context.WithCancel(ctx)
+	var timer *time.Timer
+	if timeout > 0 {
+		timer = time.AfterFunc(timeout, cancel)
+	}
+
+	resp, err := s.client.Do(req.Clone(actx))
+	if timer != nil && !timer.Stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel()
+		return nil, fmt.Errorf("%w (%v)", errAttemptTimeout, timeout)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// backoff devolve a espera antes da próxima tentativa: exponencial com
+// "full jitter", limitada a BackoffMax
+func backoff(rc RetryConfig, attempt int) time.Duration {
+	d := rc.BackoffInitial << (attempt - 1)
+	if d <= 0 || (rc.BackoffMax > 0 && d > rc.BackoffMax) {
+		d = rc.BackoffMax
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d))) + 1
+}
+
+// retryAfter interpreta o header Retry-After (segundos ou data HTTP)
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// breaker é um circuit breaker simples por host: após threshold falhas
+// seguidas rejeita as requisições por cooldown e depois libera uma de teste
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+// Os breakers ficam na instância para lembrar das falhas entre invocações
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*breaker{}
+)
+
+func breakerFor(host string, rc RetryConfig) *breaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	b, ok := breakers[host]
+	if !ok {
+		b = &breaker{}
+		breakers[host] = b
+	}
+	b.mu.Lock()
+	b.threshold, b.cooldown = rc.BreakerThreshold, rc.BreakerCooldown
+	b.mu.Unlock()
+	return b
+}
+
+// allow diz se a requisição pode seguir e se ela é o teste do circuito meio
+// aberto, que precisa terminar em success, failure ou release
+func (b *breaker) allow() (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true, false
+	}
+	if time.Since(b.openedAt) < b.cooldown || b.probing {
+		return false, false
+	}
+	// Meio aberto: uma requisição de teste decide se o circuito fecha
+	b.probing = true
+	return true, true
+}
+
+// release encerra o teste sem resultado, deixando a próxima requisição testar
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.threshold > 0 && b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			logrus.Warnf("Circuit breaker open after %d consecutive failures", b.failures)
+		}
+		b.openedAt = time.Now()
+	}
+}
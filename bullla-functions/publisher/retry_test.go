@@ -0,0 +1,115 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryableStatus(t *testing.T) {
+	tests := map[int]bool{
+		http.StatusInternalServerError: true,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusRequestTimeout:      true,
+		http.StatusTooManyRequests:     true,
+		http.StatusBadRequest:          false,
+		http.StatusUnauthorized:        false,
+		http.StatusNotFound:            false,
+		http.StatusOK:                  false,
+	}
+	for code, want := range tests {
+		if got := retryableStatus(code); got != want {
+			t.Errorf("retryableStatus(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"circuito aberto", ErrCircuitOpen, http.StatusServiceUnavailable},
+		{"corpo grande demais", fmt.Errorf("página 1: %w", ErrBodyTooLarge), http.StatusBadGateway},
+		{"orçamento esgotado", ErrRetryBudget, http.StatusGatewayTimeout},
+		{"timeout da tentativa", &SourceError{Attempts: 3, Err: errAttemptTimeout}, http.StatusGatewayTimeout},
+		{"prazo da execução", context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{"origem recusou", &SourceError{StatusCode: http.StatusNotFound, Attempts: 1, Err: errors.New("404")}, http.StatusFailedDependency},
+		{"origem limitou", &SourceError{StatusCode: http.StatusTooManyRequests, Attempts: 4, Err: errors.New("429")}, http.StatusBadGateway},
+		{"origem falhou", &SourceError{StatusCode: http.StatusBadGateway, Attempts: 4, Err: errors.New("502")}, http.StatusBadGateway},
+		{"sem resposta", &SourceError{Attempts: 4, Err: errors.New("connection refused")}, http.StatusBadGateway},
+		{"outro erro", errors.New("erro ao ler o checkpoint"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTTPStatus(fmt.Errorf("página 2: %w", tt.err)); got != tt.want {
+				t.Errorf("HTTPStatus = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerReleasesCancelledProbe(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("block") != "":
+			select {
+			case <-block:
+			case <-r.Context().Done():
+			}
+		case fail.Load():
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	cfg := loadTestConfig(t, map[string]string{
+		"ENDPOINT_SERVER":    srv.URL,
+		"FETCH_MAX_ATTEMPTS": "1",
+		"BREAKER_THRESHOLD":  "1",
+		"BREAKER_COOLDOWN":   "1ms",
+	})
+	s := &httpSource{cfg: cfg, client: srv.Client()}
+	get := func(ctx context.Context, query string) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/"+query, nil)
+		resp, err := s.do(ctx, req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// Uma falha abre o circuito
+	if err := get(context.Background(), ""); err == nil {
+		t.Fatal("want erro com o 500")
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// O teste do meio aberto é cancelado no meio da requisição
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := get(ctx, "?block=1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("teste cancelado = %v, want context.DeadlineExceeded", err)
+	}
+
+	// O próximo teste pode seguir e, com a origem de volta, fecha o circuito
+	fail.Store(false)
+	if err := get(context.Background(), ""); err != nil {
+		t.Fatalf("depois do teste cancelado = %v, want nil", err)
+	}
+	if err := get(context.Background(), ""); err != nil {
+		t.Fatalf("com o circuito fechado = %v, want nil", err)
+	}
+}
@@ -0,0 +1,201 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Route envia ao Topic os registros que casam com Match (campos com
+// exatamente esses valores) e com a expressão CEL Filter. As rotas são
+// avaliadas na ordem; os registros que não casam com nenhuma vão para o
+// TOPIC_ID.
+type Route struct {
+	Topic    string                 `json:"topic"`
+	Match    map[string]interface{} `json:"match,omitempty"`
+	Filter   string                 `json:"filter,omitempty"`
+	Settings *TopicSettings         `json:"settings,omitempty"`
+
+	filter *Filter
+}
+
+// TopicSettings ajusta o batching e o flow control da publicação em um
+// tópico. Os campos vazios mantêm os valores do PublishConfig.
+type TopicSettings struct {
+	DelayThreshold         string `json:"delay_threshold,omitempty"`
+	CountThreshold         int    `json:"count_threshold,omitempty"`
+	ByteThreshold          int    `json:"byte_threshold,omitempty"`
+	NumGoroutines          int    `json:"num_goroutines,omitempty"`
+	Timeout                string `json:"timeout,omitempty"`
+	BufferedByteLimit      int    `json:"buffered_byte_limit,omitempty"`
+	MaxOutstandingMessages int    `json:"max_outstanding_messages,omitempty"`
+	MaxOutstandingBytes    int    `json:"max_outstanding_bytes,omitempty"`
+	FlowControlBehavior    string `json:"flow_control_behavior,omitempty"`
+
+	delay   time.Duration
+	timeout time.Duration
+}
+
+// merge sobrepõe as configurações da rota às de base
+func (s *TopicSettings) merge(base PublishConfig) PublishConfig {
+	if s == nil {
+		return base
+	}
+	if s.delay > 0 {
+		base.DelayThreshold = s.delay
+	}
+	if s.CountThreshold > 0 {
+		base.CountThreshold = s.CountThreshold
+	}
+	if s.ByteThreshold > 0 {
+		base.ByteThreshold = s.ByteThreshold
+	}
+	if s.NumGoroutines > 0 {
+		base.NumGoroutines = s.NumGoroutines
+	}
+	if s.timeout > 0 {
+		base.Timeout = s.timeout
+	}
+	if s.BufferedByteLimit > 0 {
+		base.BufferedByteLimit = s.BufferedByteLimit
+	}
+	if s.MaxOutstandingMessages > 0 {
+		base.MaxOutstandingMessages = s.MaxOutstandingMessages
+	}
+	if s.MaxOutstandingBytes > 0 {
+		base.MaxOutstandingBytes = s.MaxOutstandingBytes
+	}
+	if s.FlowControlBehavior != "" {
+		base.LimitExceededBehavior = s.FlowControlBehavior
+	}
+	return base
+}
+
+// matches diz se o registro vai para o tópico da rota. Um erro na avaliação
+// do filtro (campo ausente, por exemplo) conta como não casar.
+func (r *Route) matches(rec Record) bool {
+	if !matchFilters(rec, r.Match) {
+		return false
+	}
+	ok, _ := r.filter.Match(rec)
+	return ok
+}
+
+// routeSettings devolve as configurações do tópico nas rotas, se houver
+func routeSettings(routes []*Route, topic string) *TopicSettings {
+	for _, route := range routes {
+		if route.Topic == topic {
+			return route.Settings
+		}
+	}
+	return nil
+}
+
+// TopicResult resume a publicação em um dos tópicos
+type TopicResult struct {
+	Selected  uint64 `json:"selected"`
+	Bytes     uint64 `json:"bytes"` // payload dos selecionados
+	Published uint64 `json:"published"`
+	Errors    uint64 `json:"errors"`
+}
+
+// Os arquivos de rotas são lidos uma vez por instância
+var (
+	routeFilesMu sync.Mutex
+	routeFiles   = map[string][]*Route{}
+)
+
+// loadRoutes lê as rotas do JSON inline (ROUTES) ou do arquivo (ROUTES_FILE)
+func loadRoutes(inline, path string) ([]*Route, error) {
+	if inline != "" {
+		return parseRoutes([]byte(inline), "ROUTES")
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	routeFilesMu.Lock()
+	defer routeFilesMu.Unlock()
+	if routes, ok := routeFiles[path]; ok {
+		return routes, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler ROUTES_FILE: %w", err)
+	}
+	routes, err := parseRoutes(data, "ROUTES_FILE")
+	if err != nil {
+		return nil, err
+	}
+	routeFiles[path] = routes
+	return routes, nil
+}
+
+func parseRoutes(data []byte, source string) ([]*Route, error) {
+	var routes []*Route
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&routes); err != nil {
+		return nil, fmt.Errorf("%s inválido: %w", source, err)
+	}
+
+	settings := map[string]*TopicSettings{}
+	for i, route := range routes {
+		if route == nil {
+			return nil, fmt.Errorf("%s inválido: rota %d vazia", source, i)
+		}
+		if !topicIDPattern.MatchString(route.Topic) || strings.HasPrefix(route.Topic, "goog") {
+			return nil, fmt.Errorf("%s inválido: nome de tópico inválido %q na rota %d", source, route.Topic, i)
+		}
+		if len(route.Match) == 0 && route.Filter == "" {
+			return nil, fmt.Errorf("%s inválido: rota %d sem match nem filter", source, i)
+		}
+		for field, value := range route.Match {
+			switch value.(type) {
+			case nil, string, float64, bool:
+			default:
+				return nil, fmt.Errorf("%s inválido: o valor de %s na rota %d deve ser string, número, booleano ou null", source, field, i)
+			}
+		}
+		if route.Filter != "" {
+			f, err := compileFilter(route.Filter)
+			if err != nil {
+				return nil, fmt.Errorf("%s inválido: rota %d: %w", source, i, err)
+			}
+			route.filter = f
+		}
+
+		if s := route.Settings; s != nil {
+			if s.DelayThreshold != "" {
+				d, err := time.ParseDuration(s.DelayThreshold)
+				if err != nil || d <= 0 {
+					return nil, fmt.Errorf("%s inválido: delay_threshold %q na rota %d", source, s.DelayThreshold, i)
+				}
+				s.delay = d
+			}
+			if s.Timeout != "" {
+				d, err := time.ParseDuration(s.Timeout)
+				if err != nil || d <= 0 {
+					return nil, fmt.Errorf("%s inválido: timeout %q na rota %d", source, s.Timeout, i)
+				}
+				s.timeout = d
+			}
+			if s.CountThreshold < 0 || s.ByteThreshold < 0 || s.NumGoroutines < 0 || s.BufferedByteLimit < 0 || s.MaxOutstandingMessages < 0 || s.MaxOutstandingBytes < 0 {
+				return nil, fmt.Errorf("%s inválido: valores negativos em settings na rota %d", source, i)
+			}
+			// Cada tópico tem um só PublishSettings
+			if settings[route.Topic] != nil {
+				return nil, fmt.Errorf("%s inválido: settings do tópico %s em mais de uma rota", source, route.Topic)
+			}
+			settings[route.Topic] = s
+		}
+	}
+	// As rotas do mesmo tópico compartilham as configurações
+	for _, route := range routes {
+		route.Settings = settings[route.Topic]
+	}
+	return routes, nil
+}
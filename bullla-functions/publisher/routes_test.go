@@ -0,0 +1,64 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRunRoutesTopicSettings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 1, "region": "br"}, {"id": 2, "region": "us"}, {"id": 3, "region": "br"}]`)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, "topico", "pedidos-br")
+	cfg := loadTestConfig(t, map[string]string{
+		"ENDPOINT_SERVER":         srv.URL,
+		"TOPIC_ID":                "topico",
+		"PUBLISH_COUNT_THRESHOLD": "100",
+		"ROUTES":                  `[{"topic": "pedidos-br", "match": {"region": "br"}, "settings": {"count_threshold": 5, "delay_threshold": "50ms"}}]`,
+	})
+	p, err := NewPipeline(cfg, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for topic, want := range map[string]uint64{"topico": 1, "pedidos-br": 2} {
+		if tr := result.Topics[topic]; tr == nil || tr.Published != want {
+			t.Errorf("%s: %+v, want %d publicados", topic, tr, want)
+		}
+	}
+
+	// Só o tópico da rota recebe as settings dela
+	if s := p.topic("pedidos-br").PublishSettings; s.CountThreshold != 5 || s.DelayThreshold != 50*time.Millisecond {
+		t.Errorf("pedidos-br: count=%d delay=%v, want 5, 50ms", s.CountThreshold, s.DelayThreshold)
+	}
+	if s := p.Topic.PublishSettings; s.CountThreshold != 100 {
+		t.Errorf("topico: count=%d, want 100", s.CountThreshold)
+	}
+
+	// A próxima invocação reaproveita os tópicos; outras settings, não
+	again, err := NewPipeline(cfg, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Topic != p.Topic || again.topic("pedidos-br") != p.topic("pedidos-br") {
+		t.Error("os tópicos foram criados de novo com as mesmas settings")
+	}
+	c := *cfg
+	c.Publish.CountThreshold = 10
+	other, err := NewPipeline(&c, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Topic == p.Topic {
+		t.Error("o tópico foi reaproveitado com outras settings")
+	}
+}
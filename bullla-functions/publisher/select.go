@@ -0,0 +1,128 @@
+package publisher
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Modos de seleção (SELECT_MODE)
+const (
+	selectHead = "head"
+	selectTail = "tail"
+)
+
+// Selection escolhe quais registros, entre os que passaram pelos filtros,
+// são publicados
+type Selection struct {
+	// Limit é o máximo de registros publicados (0 = todos)
+	Limit int
+	// Offset descarta os primeiros registros (head) ou os últimos (tail)
+	Offset int
+	// Sample publica só esse percentual dos registros (0 = todos), escolhidos
+	// pelo hash do ID: o mesmo registro é sempre amostrado do mesmo jeito
+	Sample float64
+	// Mode é head (do início, parando a leitura no limite) ou tail (do final,
+	// retendo até Limit+Offset registros em memória)
+	Mode string
+}
+
+// Validate confere os valores da seleção
+func (s Selection) Validate() error {
+	switch {
+	case s.Limit < 0:
+		return fmt.Errorf("limit: deve ser maior ou igual a zero")
+	case s.Offset < 0:
+		return fmt.Errorf("offset: deve ser maior ou igual a zero")
+	case s.Sample < 0 || s.Sample > 100:
+		return fmt.Errorf("sample: deve estar entre 0 e 100")
+	}
+	switch s.Mode {
+	case "", selectHead, selectTail:
+		return nil
+	default:
+		return fmt.Errorf("mode: deve ser %s ou %s", selectHead, selectTail)
+	}
+}
+
+// active diz se a seleção pode deixar registros de fora
+func (s Selection) active() bool {
+	return s.Limit > 0 || s.Offset > 0 || (s.Sample > 0 && s.Sample < 100)
+}
+
+// sampled diz se o registro identificado por key entra na amostra
+func (s Selection) sampled(key []byte) bool {
+	if s.Sample <= 0 || s.Sample >= 100 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write(key)
+	return h.Sum64()%10000 < uint64(s.Sample*100)
+}
+
+// outgoing é um registro pronto para publicar
+type outgoing struct {
+	id    string // ID para a deduplicação ("" sem DEDUP_STORE)
+	ref   string // ID_FIELD do registro, para o relatório
+	tag   string // origem do registro em um fan-in
+	topic string // tópico escolhido pelas rotas
+	key   string // ordering key
+	attrs map[string]string
+	data  []byte // payload publicado, comprimido ou não
+	raw   []byte // payload em JSON
+	blob  string // key do blob do claim-check, removido se a mensagem não sair
+	hw    string // valor do campo da high-water mark na origem
+}
+
+// selector aplica o limite e o offset aos registros, na ordem em que chegam
+type selector struct {
+	Selection
+	count int        // registros recebidos (head)
+	taken int        // registros liberados (head)
+	queue []outgoing // registros retidos (tail)
+}
+
+func newSelector(s Selection) *selector {
+	return &selector{Selection: s}
+}
+
+// add recebe o próximo registro e devolve os que já podem ser publicados e
+// os que foram descartados pelo offset ou pelo limite
+func (s *selector) add(o outgoing) (ready, dropped []outgoing) {
+	if s.Mode != selectTail {
+		s.count++
+		if s.count <= s.Offset {
+			return nil, []outgoing{o}
+		}
+		s.taken++
+		return []outgoing{o}, nil
+	}
+
+	s.queue = append(s.queue, o)
+	if s.Limit == 0 {
+		// Sem limite, só os últimos Offset precisam esperar o fim da leitura
+		if len(s.queue) > s.Offset {
+			ready, s.queue = s.queue[:1], s.queue[1:]
+		}
+		return ready, nil
+	}
+	if len(s.queue) > s.Limit+s.Offset {
+		dropped, s.queue = s.queue[:1], s.queue[1:]
+	}
+	return nil, dropped
+}
+
+// full diz se o limite foi atingido e a leitura pode parar (só no modo head)
+func (s *selector) full() bool {
+	return s.Mode != selectTail && s.Limit > 0 && s.taken >= s.Limit
+}
+
+// flush devolve, ao final da leitura, os registros retidos no modo tail
+func (s *selector) flush() (ready, dropped []outgoing) {
+	n := len(s.queue) - s.Offset
+	if n < 0 {
+		n = 0
+	}
+	ready, dropped = s.queue[:n], s.queue[n:]
+	s.queue = nil
+	return ready, dropped
+}
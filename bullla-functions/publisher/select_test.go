@@ -0,0 +1,88 @@
+package publisher
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// runSelector passa os registros 0..n-1 pelo seletor e devolve os IDs
+// liberados, na ordem, e quando o limite parou a leitura
+func runSelector(sel Selection, n int) ([]string, int) {
+	s := newSelector(sel)
+	var ids []string
+	collect := func(ready, _ []outgoing) {
+		for _, o := range ready {
+			ids = append(ids, o.ref)
+		}
+	}
+	stoppedAt := -1
+	for i := 0; i < n; i++ {
+		collect(s.add(outgoing{ref: fmt.Sprint(i)}))
+		if s.full() {
+			stoppedAt = i
+			break
+		}
+	}
+	collect(s.flush())
+	return ids, stoppedAt
+}
+
+func TestSelector(t *testing.T) {
+	tests := []struct {
+		name      string
+		sel       Selection
+		want      []string
+		stoppedAt int
+	}{
+		{"tudo", Selection{}, []string{"0", "1", "2", "3", "4"}, -1},
+		{"head com limite", Selection{Limit: 2}, []string{"0", "1"}, 1},
+		{"head com offset e limite", Selection{Offset: 1, Limit: 2}, []string{"1", "2"}, 2},
+		{"head com limite maior que a origem", Selection{Limit: 10}, []string{"0", "1", "2", "3", "4"}, -1},
+		{"tail com limite", Selection{Mode: selectTail, Limit: 2}, []string{"3", "4"}, -1},
+		{"tail com offset e limite", Selection{Mode: selectTail, Offset: 1, Limit: 2}, []string{"2", "3"}, -1},
+		{"tail só com offset", Selection{Mode: selectTail, Offset: 2}, []string{"0", "1", "2"}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stoppedAt := runSelector(tt.sel, 5)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("liberados = %v, want %v", got, tt.want)
+			}
+			if stoppedAt != tt.stoppedAt {
+				t.Errorf("parou em %d, want %d", stoppedAt, tt.stoppedAt)
+			}
+		})
+	}
+}
+
+func TestSelectionSampled(t *testing.T) {
+	sel := Selection{Sample: 30}
+	n := 0
+	for i := 0; i < 10000; i++ {
+		key := []byte(fmt.Sprint(i))
+		if sel.sampled(key) != sel.sampled(key) {
+			t.Fatalf("amostragem não determinística para %s", key)
+		}
+		if sel.sampled(key) {
+			n++
+		}
+	}
+	if n < 2700 || n > 3300 {
+		t.Errorf("%d de 10000 amostrados, want ~3000", n)
+	}
+	if !(Selection{}).sampled([]byte("x")) || !(Selection{Sample: 100}).sampled([]byte("x")) {
+		t.Error("sem amostragem todos os registros devem entrar")
+	}
+}
+
+func TestSelectionValidate(t *testing.T) {
+	for _, sel := range []Selection{{Limit: -1}, {Offset: -1}, {Sample: 101}, {Mode: "middle"}} {
+		if err := sel.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want erro", sel)
+		}
+	}
+	if err := (Selection{Limit: 1, Offset: 2, Sample: 50, Mode: selectTail}).Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
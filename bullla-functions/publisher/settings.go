@@ -0,0 +1,8 @@
+package publisher
+
+import "github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/pubsettings"
+
+// PublishConfig é o batching e o flow control da publicação, lidos do
+// ambiente e aplicados a todos os tópicos. Fica em pubsettings para que as
+// outras functions leiam a mesma configuração sem importar este pacote.
+type PublishConfig = pubsettings.PublishConfig
@@ -0,0 +1,162 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Tipos de origem (SOURCE_TYPE)
+const (
+	sourceHTTP  = "http"
+	sourceFile  = "file"
+	sourceGlob  = "glob"
+	sourceStdin = "stdin"
+)
+
+// FetchStats resume o que foi lido da origem. Para arquivos, cada arquivo
+// lido conta como uma página. Na origem HTTP, WireBytes são os bytes
+// recebidos (comprimidos ou não) e Bytes os bytes já descomprimidos.
+type FetchStats struct {
+	Pages     int   `json:"pages"`
+	Records   int   `json:"records"`
+	WireBytes int64 `json:"wire_bytes"`
+	Bytes     int64 `json:"bytes"`
+}
+
+// add soma as estatísticas de outra leitura
+func (s *FetchStats) add(o FetchStats) {
+	s.Pages += o.Pages
+	s.Records += o.Records
+	s.WireBytes += o.WireBytes
+	s.Bytes += o.Bytes
+}
+
+// Source é a origem dos registros a publicar. Fetch entrega cada registro
+// a fn conforme é lido; um erro de fn interrompe a leitura.
+type Source interface {
+	Fetch(ctx context.Context, fn func(Record) error) (FetchStats, error)
+}
+
+// NewSource cria a origem configurada em SOURCE_TYPE, ou o fan-in das
+// origens listadas em SOURCES
+func NewSource(cfg *Config) (Source, error) {
+	if len(cfg.Sources) > 0 {
+		return newMultiSource(cfg)
+	}
+
+	switch cfg.SourceType {
+	case sourceHTTP:
+		client, err := sourceHTTPClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &httpSource{cfg: cfg, client: client}, nil
+	case sourceFile:
+		if cfg.SourcePath == "" {
+			return nil, fmt.Errorf("SOURCE_PATH is not set")
+		}
+		return &fileSource{cfg: cfg, path: cfg.SourcePath}, nil
+	case sourceGlob:
+		if cfg.SourcePath == "" {
+			return nil, fmt.Errorf("SOURCE_PATH is not set")
+		}
+		return &globSource{cfg: cfg, pattern: cfg.SourcePath}, nil
+	case sourceStdin:
+		return &readerSource{cfg: cfg, name: "stdin", r: os.Stdin}, nil
+	default:
+		return nil, fmt.Errorf("SOURCE_TYPE inválido: %q", cfg.SourceType)
+	}
+}
+
+// fileSource lê os registros de um arquivo local (ex.: um dump da API)
+type fileSource struct {
+	cfg  *Config
+	path string
+}
+
+func (s *fileSource) Fetch(ctx context.Context, fn func(Record) error) (FetchStats, error) {
+	logrus.Debugf("Reading file: %s", s.path)
+	f, err := os.Open(s.path)
+	if err != nil {
+		return FetchStats{}, fmt.Errorf("erro ao abrir o arquivo: %w", err)
+	}
+	defer f.Close()
+
+	src := &readerSource{cfg: s.cfg, name: s.path, r: f, format: formatFromPath(s.path)}
+	return src.Fetch(ctx, fn)
+}
+
+// globSource lê, em ordem alfabética, todos os arquivos que casam com o padrão
+type globSource struct {
+	cfg     *Config
+	pattern string
+}
+
+func (s *globSource) Fetch(ctx context.Context, fn func(Record) error) (FetchStats, error) {
+	var stats FetchStats
+
+	paths, err := filepath.Glob(s.pattern)
+	if err != nil {
+		return stats, fmt.Errorf("SOURCE_PATH inválido: %w", err)
+	}
+	sort.Strings(paths)
+	logrus.Debugf("%d files match %s", len(paths), s.pattern)
+
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+
+		file := &fileSource{cfg: s.cfg, path: path}
+		st, err := file.Fetch(ctx, fn)
+		stats.add(st)
+		if err != nil {
+			return stats, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return stats, nil
+}
+
+// readerSource decodifica um único stream (arquivo aberto ou stdin)
+type readerSource struct {
+	cfg    *Config
+	name   string
+	r      io.Reader
+	format string
+}
+
+func (s *readerSource) Fetch(ctx context.Context, fn func(Record) error) (FetchStats, error) {
+	format := s.cfg.Format
+	if format == "" {
+		format = s.format
+	}
+	if format == "" {
+		format = formatJSON
+	}
+
+	logrus.Debugf("Decoding %s as %s", s.name, format)
+	count, _, err := decodeBody(s.r, format, s.cfg, "", fn)
+	return FetchStats{Pages: 1, Records: count}, err
+}
+
+// formatFromPath deduz o formato pela extensão do arquivo
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return formatNDJSON
+	case ".csv":
+		return formatCSV
+	default:
+		return formatJSON
+	}
+}
@@ -0,0 +1,100 @@
+package publisher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Rejection é um registro que não passou na validação do schema
+type Rejection struct {
+	ID      string   `json:"id"`
+	Reasons []string `json:"reasons"`
+}
+
+// Validator valida o payload de cada registro contra um JSON Schema
+type Validator struct {
+	schema *jsonschema.Schema
+}
+
+// Os schemas são compilados uma vez por instância
+var (
+	validatorsMu sync.Mutex
+	validators   = map[string]*Validator{}
+)
+
+// loadValidator compila (ou devolve do cache) o schema do arquivo informado
+func loadValidator(path string) (*Validator, error) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	if v, ok := validators[path]; ok {
+		return v, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler SCHEMA_FILE: %w", err)
+	}
+	c := jsonschema.NewCompiler()
+	c.AssertFormat = true
+	if err := c.AddResource(path, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("SCHEMA_FILE inválido: %w", err)
+	}
+	schema, err := c.Compile(path)
+	if err != nil {
+		return nil, fmt.Errorf("SCHEMA_FILE inválido: %w", err)
+	}
+
+	v := &Validator{schema: schema}
+	validators[path] = v
+	return v, nil
+}
+
+// Validate devolve os motivos pelos quais o payload é inválido, ou nil se for válido
+func (v *Validator) Validate(payload []byte) []string {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return []string{fmt.Sprintf("JSON inválido: %v", err)}
+	}
+
+	err := v.schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return []string{err.Error()}
+	}
+
+	// Só as folhas trazem o motivo concreto de cada campo
+	var reasons []string
+	var collect func(*jsonschema.ValidationError)
+	collect = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			location := ve.InstanceLocation
+			if location == "" {
+				location = "/"
+			}
+			reasons = append(reasons, fmt.Sprintf("%s: %s", location, ve.Message))
+			return
+		}
+		for _, cause := range ve.Causes {
+			collect(cause)
+		}
+	}
+	collect(ve)
+	return reasons
+}
+
+// rejectEnvelope é o payload publicado no tópico de rejeitados
+type rejectEnvelope struct {
+	Record json.RawMessage `json:"record"`
+	Errors []string        `json:"errors"`
+}
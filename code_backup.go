@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"os"
@@ -97,19 +96,6 @@ func main(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Body.Close()
 
-	// Lendo o corpo da resposta
-	logrus.Debug("Request successful, reading body")
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logrus.Fatalf("Erro ao ler o corpo da resposta: %v", err)
-	}
-
-	// Parse do JSON recebido
-	var messages []Message
-	if err := json.Unmarshal(body, &messages); err != nil {
-		logrus.Fatalf("Erro ao fazer parse do JSON: %v", err)
-	}
-
 	if err := checkNetworkConnectivity(); err != nil {
 		logrus.Fatalf("Network connectivity test failed: %v", err)
 	} else {
@@ -129,8 +115,20 @@ func main(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
+	// Lendo o corpo da resposta em streaming, um elemento por vez
+	logrus.Debug("Request successful, decoding body")
+	dec := json.NewDecoder(resp.Body)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		logrus.Fatalf("Erro ao fazer parse do JSON: esperado array (%v, %v)", tok, err)
+	}
+
 	// Publicando cada mensagem individualmente
-	for _, msg := range messages {
+	for dec.More() {
+		var msg Message
+		if err := dec.Decode(&msg); err != nil {
+			logrus.Fatalf("Erro ao fazer parse do JSON: %v", err)
+		}
+
 		messageJSON, err := json.Marshal(msg)
 		if err != nil {
 			logrus.Errorf("Erro ao converter mensagem para JSON: %v", err)
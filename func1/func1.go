@@ -2,22 +2,15 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 
 	"cloud.google.com/go/pubsub"
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher"
 	"google.golang.org/api/option"
 )
 
-type Message struct {
-	ID      string `json:"id"`
-	Message string `json:"message"`
-}
-
 type PubsubService struct {
 	projectId string
 	client    *pubsub.Client
@@ -38,45 +31,12 @@ func NewPubsubService() (*PubsubService, error) {
 	}, nil
 }
 
-func (s *PubsubService) PublisherMessage(topicID, message string) (string, error) {
-	ctx := context.Background()
-	topic := s.client.Topic(topicID)
-	defer topic.Stop()
-
-	result := topic.Publish(ctx, &pubsub.Message{
-		Data: []byte(message),
-	})
-
-	id, err := result.Get(ctx)
-	if err != nil {
-		return "", err
-	}
-	msg := fmt.Sprintf("Published message ID: %s", id)
-	fmt.Println(msg)
-	return msg, nil
-}
-
 func main() {
-	// URL do endpoint
-	url := os.Getenv("ENDPOINT_SERVER")
-
-	// Fazendo a requisição GET
-	resp, err := http.Get(url)
+	// Mesma configuração da Cloud Function: SOURCE_TYPE=file e SOURCE_PATH
+	// permitem rodar localmente a partir de um dump em vez do ENDPOINT_SERVER
+	cfg, err := publisher.LoadConfig()
 	if err != nil {
-		log.Fatalf("Erro ao fazer a requisição: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Lendo o corpo da resposta
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("Erro ao ler o corpo da resposta: %v", err)
-	}
-
-	// Parse do JSON recebido
-	var messages []Message
-	if err := json.Unmarshal(body, &messages); err != nil {
-		log.Fatalf("Erro ao fazer parse do JSON: %v", err)
+		log.Fatalf("Configuração inválida: %v", err)
 	}
 
 	service, err := NewPubsubService()
@@ -84,21 +44,22 @@ func main() {
 		fmt.Println("Error creating service:", err)
 		return
 	}
+	defer service.client.Close()
 
-	topicID := os.Getenv("TOPIC_ID")
+	p, err := publisher.NewPipeline(cfg, service.client)
+	if err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+	defer publisher.StopTopics()
 
-	// Publicando cada mensagem individualmente
-	for _, msg := range messages {
-		messageJSON, err := json.Marshal(msg)
-		if err != nil {
-			log.Printf("Erro ao converter mensagem para JSON: %v", err)
-			continue
-		}
+	// Publicando cada mensagem conforme é lida da origem
+	result, err := p.Run(context.Background())
+	if err != nil {
+		log.Fatalf("Erro ao ler a origem: %v", err)
+	}
 
-		_, err = service.PublisherMessage(topicID, string(messageJSON))
-		if err != nil {
-			fmt.Println("Error publishing message:", err)
-			return
-		}
+	fmt.Printf("Publicadas %d de %d mensagens (%d páginas, %d erros, %d rejeitadas, %d duplicadas)\n", result.Published, result.Records, result.Pages, result.Errors, result.RejectedCount(), result.Duplicate)
+	if result.Errors > 0 {
+		os.Exit(1)
 	}
 }
@@ -10,11 +10,13 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"cloud.google.com/go/pubsub"
+	"github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher/message"
 	"google.golang.org/api/option"
 )
 
@@ -30,7 +32,8 @@ func main() {
 	subscriptionID := "example-subscription3"
 
 	// Criando o cliente Pub/Sub
-	client, err := pubsub.NewClient(ctx, projectId, option.WithEndpoint(emulatorHost))
+	opts := []option.ClientOption{option.WithEndpoint(emulatorHost)}
+	client, err := pubsub.NewClient(ctx, projectId, opts...)
 	if err != nil {
 		log.Fatalf("Erro ao criar o cliente Pub/Sub: %v", err)
 	}
@@ -58,17 +61,84 @@ func main() {
 
 	url := "http://localhost:3000/func2"
 
+	// Mesmo BlobStore do publisher, para resolver as mensagens publicadas
+	// por claim-check
+	blobs, err := message.OpenBlobStore(os.Getenv("CLAIM_CHECK_STORE"), getEnv("CLAIM_CHECK_PATH", "state/blobs"))
+	if err != nil {
+		log.Fatalf("Erro ao abrir o BlobStore: %v", err)
+	}
+
+	// Tópicos com schema: a definição vem do MESSAGE_SCHEMA_FILE ou, sem ele,
+	// do registro de schemas do Pub/Sub, pela revisão de cada mensagem
+	schemas := &schemaCache{opts: opts, schemas: map[string]*message.Schema{}}
+	if path := os.Getenv("MESSAGE_SCHEMA_FILE"); path != "" {
+		if schemas.local, err = message.LoadSchema(path, strings.ToLower(os.Getenv("MESSAGE_SCHEMA_TYPE"))); err != nil {
+			log.Fatalf("Erro ao carregar o schema: %v", err)
+		}
+	}
+
+	// Campos cifrados pelo publisher: sem ENCRYPTION_KMS, ou sem acesso à
+	// chave, a mensagem segue com os valores cifrados
+	kms, err := message.OpenKMS(os.Getenv("ENCRYPTION_KMS"), os.Getenv("ENCRYPTION_KEYRING_FILE"))
+	if err != nil {
+		log.Fatalf("Erro ao abrir o KMS: %v", err)
+	}
+	var decryptor *message.FieldDecryptor
+	if kms != nil {
+		decryptor = message.NewFieldDecryptor(kms)
+	}
+
 	err = subscription.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
 		mu.Lock()
 		defer mu.Unlock()
 		received++
 		messageID := msg.ID
-		fmt.Printf("Mensagem recebida: %s, ID: %s\n", string(msg.Data), messageID)
+
+		// Payload grande: a mensagem traz só a referência ao blob
+		data := msg.Data
+		if msg.Attributes[message.AttrClaimCheck] != "" {
+			if blobs == nil {
+				fmt.Printf("Mensagem com claim-check sem CLAIM_CHECK_STORE configurado, ID: %s\n", messageID)
+				return
+			}
+			var err error
+			if data, err = message.ResolveClaimCheck(ctx, blobs, data); err != nil {
+				fmt.Printf("Erro ao resolver o claim-check, ID: %s: %v\n", messageID, err)
+				return
+			}
+		}
+
+		// O publisher pode comprimir o payload (atributo content-encoding)
+		data, err := message.Decompress(data, msg.Attributes[message.AttrContentEncoding])
+		if err != nil {
+			fmt.Printf("Erro ao descomprimir a mensagem, ID: %s: %v\n", messageID, err)
+			return
+		}
+		// Tópico com schema: Avro ou Protobuf, em binário ou JSON
+		if encoding := msg.Attributes["googclient_schemaencoding"]; encoding != "" {
+			schema, err := schemas.get(ctx, msg.Attributes["googclient_schemaname"], msg.Attributes["googclient_schemarevisionid"])
+			if err != nil {
+				fmt.Printf("Erro ao obter o schema da mensagem, ID: %s: %v\n", messageID, err)
+				return
+			}
+			if data, err = schema.Decode(data, encoding); err != nil {
+				fmt.Printf("Erro ao decodificar a mensagem, ID: %s: %v\n", messageID, err)
+				return
+			}
+		}
+		if msg.Attributes[message.AttrEncryptedFields] != "" && decryptor != nil {
+			if plain, err := decryptor.Decrypt(ctx, data, msg.Attributes); err != nil {
+				fmt.Printf("Não foi possível decifrar a mensagem, seguindo cifrada, ID: %s: %v\n", messageID, err)
+			} else {
+				data = plain
+			}
+		}
+		fmt.Printf("Mensagem recebida: %s, ID: %s\n", string(data), messageID)
 
 		// Fazendo POST com a mensagem recebida
 		success := false
 		for i := 0; i < maxRetries; i++ {
-			err := postMessage(url, msg.Data, messageID)
+			err := postMessage(url, data, messageID)
 			if err != nil {
 				fmt.Printf("Erro ao fazer o POST (tentativa %d), ID: %s: %v\n", i+1, messageID, err)
 				time.Sleep(2 * time.Second) // Espera antes de tentar novamente
@@ -92,6 +162,70 @@ func main() {
 	fmt.Printf("Recebidas %d mensagens\n", received)
 }
 
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// schemaCache guarda os schemas já lidos do registro, por nome e revisão
+type schemaCache struct {
+	local   *message.Schema
+	opts    []option.ClientOption
+	mu      sync.Mutex
+	schemas map[string]*message.Schema
+}
+
+// get devolve o schema da mensagem a partir dos atributos
+// googclient_schemaname (projects/<projeto>/schemas/<id>) e
+// googclient_schemarevisionid
+func (c *schemaCache) get(ctx context.Context, name, revision string) (*message.Schema, error) {
+	if c.local != nil {
+		return c.local, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := name + "@" + revision
+	if s, ok := c.schemas[key]; ok {
+		return s, nil
+	}
+
+	parts := strings.Split(name, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "schemas" {
+		return nil, fmt.Errorf("googclient_schemaname inválido: %q", name)
+	}
+	sc, err := pubsub.NewSchemaClient(ctx, parts[1], c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer sc.Close()
+	id := parts[3]
+	if revision != "" {
+		id += "@" + revision
+	}
+	cfg, err := sc.Schema(ctx, id, pubsub.SchemaViewFull)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o schema %s: %w", key, err)
+	}
+
+	var schemaType string
+	switch cfg.Type {
+	case pubsub.SchemaAvro:
+		schemaType = message.SchemaAvro
+	case pubsub.SchemaProtocolBuffer:
+		schemaType = message.SchemaProtobuf
+	default:
+		return nil, fmt.Errorf("schema %s de tipo não suportado: %v", key, cfg.Type)
+	}
+	s, err := message.ParseSchema(schemaType, []byte(cfg.Definition))
+	if err != nil {
+		return nil, fmt.Errorf("schema %s inválido: %w", key, err)
+	}
+	c.schemas[key] = s
+	return s, nil
+}
+
 // Função para fazer POST com a mensagem recebida
 func postMessage(url string, message []byte, messageID string) error {
 	// Criação do payload
@@ -4,8 +4,8 @@ import (
 	"log"
 	"os"
 
-	// Blank-import the function package so the init() runs
-	_ "example.com/hello"
+	// Blank-import the publisher package so its init() registers the function
+	_ "github.com/fabmaiad/poc-gcp-go/bullla-functions/publisher"
 	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
 	"github.com/sirupsen/logrus"
 )
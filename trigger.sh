@@ -2,5 +2,7 @@ curl -m 70 -X POST https://us-central1-bullla-one-d-apps-cn-92c3.cloudfunctions.
 -H "Authorization: bearer $(gcloud auth print-identity-token)" \
 -H "Content-Type: application/json" \
 -d '{
-  "name": "Hello World"
+  "params": {"name": "Hello World"},
+  "limit": 100,
+  "dry_run": false
 }'
\ No newline at end of file